@@ -6,8 +6,16 @@ import (
 	"github.com/jumperzq86/jumper_conn/def"
 
 	"github.com/gorilla/websocket"
+	"github.com/jumperzq86/jumper_conn/impl/codec"
+	"github.com/jumperzq86/jumper_conn/impl/config"
 	"github.com/jumperzq86/jumper_conn/impl/conn"
+	"github.com/jumperzq86/jumper_conn/impl/group"
+	"github.com/jumperzq86/jumper_conn/impl/limit"
+	"github.com/jumperzq86/jumper_conn/impl/pool"
+	"github.com/jumperzq86/jumper_conn/impl/router"
+	"github.com/jumperzq86/jumper_conn/impl/rpc"
 	"github.com/jumperzq86/jumper_conn/interf"
+	"github.com/jumperzq86/jumper_conn/util"
 )
 
 func NewwsConn(c *websocket.Conn, co *def.ConnOptions, handler interf.Handler) (interf.Conn, error) {
@@ -25,3 +33,95 @@ func NewtcpConn(c net.Conn, co *def.ConnOptions, handler interf.Handler) (interf
 	}
 	return tcpConn, nil
 }
+
+//cc 可以由 def.NewConnConfig 创建，多个连接可以共享同一个 cc
+func NewwsConnWithConfig(c *websocket.Conn, cc *def.ConnConfig, handler interf.Handler) (interf.Conn, error) {
+	wsConn, err := conn.CreatewsConnWithConfig(c, cc, handler)
+	if err != nil {
+		return nil, err
+	}
+	return wsConn, nil
+}
+
+func NewtcpConnWithConfig(c net.Conn, cc *def.ConnConfig, handler interf.Handler) (interf.Conn, error) {
+	tcpConn, err := conn.CreatetcpConnWithConfig(c, cc, handler)
+	if err != nil {
+		return nil, err
+	}
+	return tcpConn, nil
+}
+
+func NewlengthFieldDecoder(lo *def.LengthFieldOptions) (interf.Decoder, error) {
+	decoder, err := codec.CreatelengthFieldDecoder(lo)
+	if err != nil {
+		return nil, err
+	}
+	return decoder, nil
+}
+
+//令牌桶限速，可通过 ConnOptions.WriteLimiter 在多个连接间共享
+func NewLimiter(rate, burst int64) interf.Limiter {
+	return limit.CreatetokenBucket(rate, burst)
+}
+
+//共享协程池，可通过 ConnOptions.WorkerPool 在多个连接间共享
+func NewWorkerPool(size, queueSize int) (interf.WorkerPool, error) {
+	workerPool, err := pool.CreateworkerPool(size, queueSize)
+	if err != nil {
+		return nil, err
+	}
+	return workerPool, nil
+}
+
+//按消息类型分发的 Handler，创建连接时作为 handler 传入
+func NewRouter(ro *def.RouterOptions) interf.Router {
+	return router.Createrouter(ro)
+}
+
+//基于连接的请求/响应 Handler，创建连接时作为 handler 传入
+func NewRpc(ro *def.RpcOptions) (interf.Rpc, error) {
+	r, err := rpc.Createrpc(ro)
+	if err != nil {
+		return nil, err
+	}
+	return r, nil
+}
+
+//从 yaml 或 json 文件加载服务定义和连接选项，envPrefix 不为空时使用环境变量覆盖文件中的值
+func LoadConfig(path string, envPrefix string) (*def.Config, error) {
+	return config.LoadConfig(path, envPrefix)
+}
+
+//format 为 def.ConfigYaml 或 def.ConfigJson
+func ParseConfig(data []byte, format string, envPrefix string) (*def.Config, error) {
+	return config.ParseConfig(data, format, envPrefix)
+}
+
+//连接组，可通过 ConnConfig.Group 使同一服务的连接自动加入
+func NewConnGroup() interf.ConnGroup {
+	return group.CreateconnGroup()
+}
+
+//运行时调整连接的选项，修改从下一帧开始生效
+func Reconfigure(c interf.Conn, cu *def.ConnUpdate) error {
+	return conn.Reconfigure(c, cu)
+}
+
+//运行时调整组中所有连接的选项，返回调整成功的连接数和第一个错误
+func ReconfigureGroup(g interf.ConnGroup, cu *def.ConnUpdate) (int, error) {
+	return conn.ReconfigureGroup(g, cu)
+}
+
+//所有连接发送队列中未发送数据的总字节数上限，0 表示不限制
+func SetWriteMemoryBudget(limit int64) {
+	conn.SetWriteMemoryBudget(limit)
+}
+
+func GetWriteMemoryUsed() int64 {
+	return conn.GetWriteMemoryUsed()
+}
+
+//连接上实际生效的 socket 选项，只支持 linux
+func GetSocketOptions(c interf.Conn) (*def.SocketOptions, error) {
+	return util.GetSocketOptions(c.GetConn())
+}
@@ -6,7 +6,10 @@ const (
 	WriteTimeout   = 10
 	AsyncWriteSize = 20
 
-	PongWait         = 60
-	PingPeriod       = (PongWait * 9) / 10
-	CloseGracePeriod = 1
+	PongWait          = 60
+	PingPeriod        = (PongWait * 9) / 10
+	CloseGracePeriod  = 1
+	CloseFlushTimeout = 5
+
+	RpcMaxConcurrent = 256
 )
@@ -0,0 +1,37 @@
+package def
+
+//服务类型
+const (
+	ServerTcp = "tcp"
+	ServerWs  = "ws"
+)
+
+//配置文件格式
+const (
+	ConfigYaml = "yaml"
+	ConfigJson = "json"
+)
+
+//从配置文件加载的服务定义
+type ServerConfig struct {
+	Name string
+	Type string //ServerTcp 或 ServerWs
+	Addr string //监听地址，如 ":8080"
+	Path string //ws 的 http 路径，默认为 "/"
+	Conn *ConnConfig
+}
+
+//从配置文件加载的配置，回调、Decoder、协程池等无法写在配置文件中的选项需要加载后设置
+type Config struct {
+	Conn    *ConnConfig //配置文件中 conn 部分的连接选项，也是各服务连接选项的默认值
+	Servers []*ServerConfig
+}
+
+func (this *Config) GetServer(name string) (*ServerConfig, bool) {
+	for _, server := range this.Servers {
+		if server.Name == name {
+			return server, true
+		}
+	}
+	return nil, false
+}
@@ -0,0 +1,184 @@
+package def
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/jumperzq86/jumper_conn/interf"
+)
+
+//时间字段使用 time.Duration 的连接配置，通过 NewConnConfig 创建，或由 ConnOptions.Config 转换
+//各字段的含义与 ConnOptions 相同
+type ConnConfig struct {
+	MaxMsgSize      int64
+	LimitTcpMsgSize bool //tcp 使用默认分帧方式时也按 MaxMsgSize 限制，默认只限制 ws
+	ReadTimeout     time.Duration
+	WriteTimeout    time.Duration
+	AsyncWriteSize  int64
+	Side            int8
+
+	OverflowPolicy  int8          //AsyncWrite 队列满时的处理策略
+	OverflowTimeout time.Duration //OverflowBlockTimeout 时的等待时间
+
+	AsyncWriteBytes int64   //发送队列中未发送数据的字节数上限，0 表示只按条数限制
+	LaneSizes       []int64 //各优先级队列的容量，下标即优先级，为空时只有一个容量为 AsyncWriteSize 的队列
+	StarvationLimit int64   //低优先级队列连续被跳过该次数后优先发送一次，0 表示不做保护
+
+	PongWait          time.Duration
+	PingPeriod        time.Duration //ws 发送 ping 的间隔，0 表示不发送，必须小于 PongWait
+	CloseGracePeriod  time.Duration
+	CloseFlushTimeout time.Duration //Handler 返回 CloseWith 时等待发送队列写出的时间，0 表示使用 CloseFlushTimeout 秒
+	ClientPing        bool          //ws 客户端也按 PingPeriod 发送 ping，用于检测服务端失效和计算往返时间
+
+	Decoder interf.Decoder //tcp 分帧方式，为 nil 时使用 4 字节大端长度头
+
+	WriteRate    int64          //出方向限速，字节/秒，0 表示不限速
+	WriteBurst   int64          //出方向突发字节数，0 时等于 WriteRate
+	WriteLimiter interf.Limiter //多个连接共享的出方向限速，为 nil 时不限制
+
+	ReadMsgRate     int64                             //入方向每秒消息数，0 表示不限制
+	ReadMsgBurst    int64                             //入方向突发消息数，0 时等于 ReadMsgRate
+	ReadByteRate    int64                             //入方向每秒字节数，0 表示不限制
+	ReadByteBurst   int64                             //入方向突发字节数，0 时等于 ReadByteRate
+	ReadLimitPolicy int8                              //超出入方向限制时的处理策略
+	ReadLimitHook   func(conn interf.Conn, err error) //超出入方向限制时回调
+
+	SlowQueuePercent  int64                             //发送队列占用达到该百分比
+	SlowQueueDuration time.Duration                     //并持续该时间时判定为慢消费者，0 表示不检测
+	SlowWriteTimeout  time.Duration                     //一次写阻塞超过该时间时判定为慢消费者，0 表示不检测
+	SlowConsumerEvict bool                              //判定为慢消费者时关闭连接
+	SlowConsumerHook  func(conn interf.Conn, err error) //判定为慢消费者时回调
+
+	WorkerPool       interf.WorkerPool //不为 nil 时 OnMessage 在共享协程池中执行
+	InboundQueueSize int64             //使用协程池时每个连接待处理消息数上限
+
+	Group interf.ConnGroup //不为 nil 时连接在 Run 时加入该组，关闭时移出
+
+	InboundMiddlewares  []interf.InboundMiddleware
+	OutboundMiddlewares []interf.OutboundMiddleware
+
+	PanicPolicy int8
+	PanicHook   func(conn interf.Conn, v interface{}, stack []byte)
+
+	HandlerErrorHook func(conn interf.Conn, err error)
+
+	HeartbeatInterval time.Duration //tcp 心跳间隔，0 表示不主动发送心跳
+	HeartbeatMiss     int64         //连续该数量的间隔内没有读到任何数据时关闭连接
+	HeartbeatReply    bool          //不主动发送心跳时仍然回复对端的心跳
+
+	IdleTimeout time.Duration //读写两个方向都没有应用数据超过该时间时关闭连接，0 表示不检测，精度为 1 秒
+
+	NoDelay         int8
+	KeepAlive       int8
+	KeepAlivePeriod time.Duration
+	ReadBuffer      int64
+	WriteBuffer     int64
+	Linger          time.Duration //小于 0 表示关闭时丢弃未发送的数据，大于 0 时精度为 1 秒
+	UserTimeout     time.Duration //精度为 1 毫秒，只支持 linux
+}
+
+//返回的错误码为 ErrInvalidConnParamCode，信息中包含不满足约束的字段
+func (this *ConnConfig) CheckValid() error {
+	if this.MaxMsgSize < 0 {
+		return invalidConnParam("MaxMsgSize", "must not be negative")
+	}
+	if this.ReadTimeout < 0 || this.WriteTimeout < 0 {
+		return invalidConnParam("ReadTimeout/WriteTimeout", "must not be negative")
+	}
+	if len(this.LaneSizes) == 0 && this.AsyncWriteSize <= 0 {
+		return invalidConnParam("AsyncWriteSize", "must be greater than 0 when LaneSizes is empty")
+	}
+	for i, size := range this.LaneSizes {
+		if size <= 0 {
+			return invalidConnParam(fmt.Sprintf("LaneSizes[%d]", i), "must be greater than 0")
+		}
+	}
+	if this.AsyncWriteBytes < 0 {
+		return invalidConnParam("AsyncWriteBytes", "must not be negative")
+	}
+	if this.StarvationLimit < 0 {
+		return invalidConnParam("StarvationLimit", "must not be negative")
+	}
+	if this.OverflowPolicy < OverflowBlock || this.OverflowPolicy > OverflowClose {
+		return invalidConnParam("OverflowPolicy", "must be one of the Overflow* constants")
+	}
+	if this.OverflowPolicy == OverflowBlockTimeout && this.OverflowTimeout <= 0 {
+		return invalidConnParam("OverflowTimeout", "must be greater than 0 when OverflowPolicy is OverflowBlockTimeout")
+	}
+	if this.WriteRate < 0 || this.WriteBurst < 0 {
+		return invalidConnParam("WriteRate/WriteBurst", "must not be negative")
+	}
+	if this.ReadMsgRate < 0 || this.ReadMsgBurst < 0 || this.ReadByteRate < 0 || this.ReadByteBurst < 0 {
+		return invalidConnParam("ReadMsgRate/ReadMsgBurst/ReadByteRate/ReadByteBurst", "must not be negative")
+	}
+	if this.ReadLimitPolicy < ReadLimitDelay || this.ReadLimitPolicy > ReadLimitClose {
+		return invalidConnParam("ReadLimitPolicy", "must be one of the ReadLimit* constants")
+	}
+	//note: 丢弃或关闭策略下，超过突发字节数的消息永远无法通过
+	if this.ReadByteRate > 0 && this.ReadLimitPolicy != ReadLimitDelay && this.MaxMsgSize > 0 {
+		burst := this.ReadByteBurst
+		if burst == 0 {
+			burst = this.ReadByteRate
+		}
+		if burst < this.MaxMsgSize {
+			return invalidConnParam("ReadByteBurst", "must not be less than MaxMsgSize when ReadLimitPolicy is not ReadLimitDelay")
+		}
+	}
+	if this.SlowQueueDuration < 0 || this.SlowWriteTimeout < 0 {
+		return invalidConnParam("SlowQueueDuration/SlowWriteTimeout", "must not be negative")
+	}
+	if this.SlowQueueDuration > 0 && (this.SlowQueuePercent <= 0 || this.SlowQueuePercent > 100) {
+		return invalidConnParam("SlowQueuePercent", "must be in (0, 100] when SlowQueueDuration is set")
+	}
+	if this.WorkerPool != nil && this.InboundQueueSize <= 0 {
+		return invalidConnParam("InboundQueueSize", "must be greater than 0 when WorkerPool is set")
+	}
+	if this.PanicPolicy < PanicClose || this.PanicPolicy > PanicContinue {
+		return invalidConnParam("PanicPolicy", "must be one of the Panic* constants")
+	}
+	if this.IdleTimeout < 0 {
+		return invalidConnParam("IdleTimeout", "must not be negative")
+	}
+	if this.NoDelay < SocketDefault || this.NoDelay > SocketOff {
+		return invalidConnParam("NoDelay", "must be one of the Socket* constants")
+	}
+	if this.KeepAlive < SocketDefault || this.KeepAlive > SocketOff {
+		return invalidConnParam("KeepAlive", "must be one of the Socket* constants")
+	}
+	if this.KeepAlivePeriod < 0 || (this.KeepAlivePeriod > 0 && this.KeepAlive == SocketOff) {
+		return invalidConnParam("KeepAlivePeriod", "must not be negative or set when KeepAlive is SocketOff")
+	}
+	if this.ReadBuffer < 0 || this.WriteBuffer < 0 {
+		return invalidConnParam("ReadBuffer/WriteBuffer", "must not be negative")
+	}
+	//note: SO_LINGER 以秒为单位，不足 1 秒会变成 0，即关闭时丢弃未发送的数据
+	if this.Linger > 0 && this.Linger < time.Second {
+		return invalidConnParam("Linger", "must be negative or at least 1s")
+	}
+	if this.UserTimeout < 0 || (this.UserTimeout > 0 && this.UserTimeout < time.Millisecond) {
+		return invalidConnParam("UserTimeout", "must be 0 or at least 1ms")
+	}
+	if this.HeartbeatInterval < 0 || this.HeartbeatMiss < 0 {
+		return invalidConnParam("HeartbeatInterval/HeartbeatMiss", "must not be negative")
+	}
+	if this.HeartbeatInterval > 0 && this.HeartbeatMiss == 0 {
+		return invalidConnParam("HeartbeatMiss", "must be greater than 0 when HeartbeatInterval is set")
+	}
+	//note: 心跳帧使用 TcpHeadSize 字节长度头发送，只支持默认的分帧方式
+	if (this.HeartbeatInterval > 0 || this.HeartbeatReply) && this.Decoder != nil {
+		return invalidConnParam("Decoder", "must be nil when heartbeat is enabled")
+	}
+	if this.PongWait < 0 || this.PingPeriod < 0 || this.CloseGracePeriod < 0 || this.CloseFlushTimeout < 0 {
+		return invalidConnParam("PongWait/PingPeriod/CloseGracePeriod/CloseFlushTimeout", "must not be negative")
+	}
+	if this.PingPeriod != 0 && this.PingPeriod >= this.PongWait {
+		return invalidConnParam("PingPeriod", "must be less than PongWait")
+	}
+	return nil
+}
+
+////////////////////////////////////////////////////////////// impl
+
+func invalidConnParam(field string, constraint string) error {
+	return New(ErrInvalidConnParamCode, fmt.Sprintf("invalid conn param: %s %s.", field, constraint))
+}
@@ -0,0 +1,282 @@
+package def
+
+import (
+	"time"
+
+	"github.com/jumperzq86/jumper_conn/interf"
+)
+
+type ConnOption func(cc *ConnConfig)
+
+//默认值取自 conf.go，默认为服务端并按 PingPeriod 发送 ws ping
+func DefaultConnConfig() *ConnConfig {
+	return &ConnConfig{
+		MaxMsgSize:        MaxMsgSize,
+		ReadTimeout:       ReadTimeout * time.Second,
+		WriteTimeout:      WriteTimeout * time.Second,
+		AsyncWriteSize:    AsyncWriteSize,
+		Side:              ServerSide,
+		PongWait:          PongWait * time.Second,
+		PingPeriod:        PingPeriod * time.Second,
+		CloseGracePeriod:  CloseGracePeriod * time.Second,
+		CloseFlushTimeout: CloseFlushTimeout * time.Second,
+	}
+}
+
+//在默认值的基础上依次应用 opts 并检查
+func NewConnConfig(opts ...ConnOption) (*ConnConfig, error) {
+	cc := DefaultConnConfig()
+	for _, opt := range opts {
+		opt(cc)
+	}
+
+	err := cc.CheckValid()
+	if err != nil {
+		return nil, err
+	}
+	return cc, nil
+}
+
+func WithMaxMsgSize(size int64) ConnOption {
+	return func(cc *ConnConfig) {
+		cc.MaxMsgSize = size
+	}
+}
+
+//tcp 使用默认分帧方式时也按 MaxMsgSize 限制读取的消息
+func WithLimitTcpMsgSize(on bool) ConnOption {
+	return func(cc *ConnConfig) {
+		cc.LimitTcpMsgSize = on
+	}
+}
+
+func WithReadTimeout(d time.Duration) ConnOption {
+	return func(cc *ConnConfig) {
+		cc.ReadTimeout = d
+	}
+}
+
+func WithWriteTimeout(d time.Duration) ConnOption {
+	return func(cc *ConnConfig) {
+		cc.WriteTimeout = d
+	}
+}
+
+func WithSide(side int8) ConnOption {
+	return func(cc *ConnConfig) {
+		cc.Side = side
+	}
+}
+
+func WithAsyncWriteSize(size int64) ConnOption {
+	return func(cc *ConnConfig) {
+		cc.AsyncWriteSize = size
+	}
+}
+
+func WithAsyncWriteBytes(limit int64) ConnOption {
+	return func(cc *ConnConfig) {
+		cc.AsyncWriteBytes = limit
+	}
+}
+
+func WithLanes(sizes []int64, starvationLimit int64) ConnOption {
+	return func(cc *ConnConfig) {
+		cc.LaneSizes = sizes
+		cc.StarvationLimit = starvationLimit
+	}
+}
+
+//timeout 只在 OverflowBlockTimeout 时使用
+func WithOverflow(policy int8, timeout time.Duration) ConnOption {
+	return func(cc *ConnConfig) {
+		cc.OverflowPolicy = policy
+		cc.OverflowTimeout = timeout
+	}
+}
+
+//period 为 0 表示不发送 ping
+func WithPing(period time.Duration, pongWait time.Duration) ConnOption {
+	return func(cc *ConnConfig) {
+		cc.PingPeriod = period
+		cc.PongWait = pongWait
+	}
+}
+
+func WithClientPing(on bool) ConnOption {
+	return func(cc *ConnConfig) {
+		cc.ClientPing = on
+	}
+}
+
+func WithCloseGracePeriod(d time.Duration) ConnOption {
+	return func(cc *ConnConfig) {
+		cc.CloseGracePeriod = d
+	}
+}
+
+//Handler 返回 CloseWith 时等待发送队列写出的时间
+func WithCloseFlushTimeout(d time.Duration) ConnOption {
+	return func(cc *ConnConfig) {
+		cc.CloseFlushTimeout = d
+	}
+}
+
+func WithDecoder(decoder interf.Decoder) ConnOption {
+	return func(cc *ConnConfig) {
+		cc.Decoder = decoder
+	}
+}
+
+func WithWriteRate(rate int64, burst int64) ConnOption {
+	return func(cc *ConnConfig) {
+		cc.WriteRate = rate
+		cc.WriteBurst = burst
+	}
+}
+
+func WithWriteLimiter(limiter interf.Limiter) ConnOption {
+	return func(cc *ConnConfig) {
+		cc.WriteLimiter = limiter
+	}
+}
+
+func WithReadMsgRate(rate int64, burst int64) ConnOption {
+	return func(cc *ConnConfig) {
+		cc.ReadMsgRate = rate
+		cc.ReadMsgBurst = burst
+	}
+}
+
+func WithReadByteRate(rate int64, burst int64) ConnOption {
+	return func(cc *ConnConfig) {
+		cc.ReadByteRate = rate
+		cc.ReadByteBurst = burst
+	}
+}
+
+func WithReadLimitPolicy(policy int8, hook func(conn interf.Conn, err error)) ConnOption {
+	return func(cc *ConnConfig) {
+		cc.ReadLimitPolicy = policy
+		cc.ReadLimitHook = hook
+	}
+}
+
+func WithSlowQueue(percent int64, d time.Duration) ConnOption {
+	return func(cc *ConnConfig) {
+		cc.SlowQueuePercent = percent
+		cc.SlowQueueDuration = d
+	}
+}
+
+func WithSlowWriteTimeout(d time.Duration) ConnOption {
+	return func(cc *ConnConfig) {
+		cc.SlowWriteTimeout = d
+	}
+}
+
+func WithSlowConsumer(evict bool, hook func(conn interf.Conn, err error)) ConnOption {
+	return func(cc *ConnConfig) {
+		cc.SlowConsumerEvict = evict
+		cc.SlowConsumerHook = hook
+	}
+}
+
+func WithWorkerPool(pool interf.WorkerPool, inboundQueueSize int64) ConnOption {
+	return func(cc *ConnConfig) {
+		cc.WorkerPool = pool
+		cc.InboundQueueSize = inboundQueueSize
+	}
+}
+
+func WithGroup(group interf.ConnGroup) ConnOption {
+	return func(cc *ConnConfig) {
+		cc.Group = group
+	}
+}
+
+func WithInboundMiddlewares(mws ...interf.InboundMiddleware) ConnOption {
+	return func(cc *ConnConfig) {
+		cc.InboundMiddlewares = append(cc.InboundMiddlewares, mws...)
+	}
+}
+
+func WithOutboundMiddlewares(mws ...interf.OutboundMiddleware) ConnOption {
+	return func(cc *ConnConfig) {
+		cc.OutboundMiddlewares = append(cc.OutboundMiddlewares, mws...)
+	}
+}
+
+func WithPanicPolicy(policy int8, hook func(conn interf.Conn, v interface{}, stack []byte)) ConnOption {
+	return func(cc *ConnConfig) {
+		cc.PanicPolicy = policy
+		cc.PanicHook = hook
+	}
+}
+
+func WithHandlerErrorHook(hook func(conn interf.Conn, err error)) ConnOption {
+	return func(cc *ConnConfig) {
+		cc.HandlerErrorHook = hook
+	}
+}
+
+func WithHeartbeat(interval time.Duration, miss int64) ConnOption {
+	return func(cc *ConnConfig) {
+		cc.HeartbeatInterval = interval
+		cc.HeartbeatMiss = miss
+	}
+}
+
+func WithHeartbeatReply(on bool) ConnOption {
+	return func(cc *ConnConfig) {
+		cc.HeartbeatReply = on
+	}
+}
+
+func WithIdleTimeout(d time.Duration) ConnOption {
+	return func(cc *ConnConfig) {
+		cc.IdleTimeout = d
+	}
+}
+
+func WithNoDelay(on bool) ConnOption {
+	return func(cc *ConnConfig) {
+		cc.NoDelay = socketSwitch(on)
+	}
+}
+
+//period 为 0 时使用系统默认的探测间隔
+func WithKeepAlive(on bool, period time.Duration) ConnOption {
+	return func(cc *ConnConfig) {
+		cc.KeepAlive = socketSwitch(on)
+		cc.KeepAlivePeriod = period
+	}
+}
+
+func WithSocketBuffer(readBuffer int64, writeBuffer int64) ConnOption {
+	return func(cc *ConnConfig) {
+		cc.ReadBuffer = readBuffer
+		cc.WriteBuffer = writeBuffer
+	}
+}
+
+func WithLinger(d time.Duration) ConnOption {
+	return func(cc *ConnConfig) {
+		cc.Linger = d
+	}
+}
+
+func WithUserTimeout(d time.Duration) ConnOption {
+	return func(cc *ConnConfig) {
+		cc.UserTimeout = d
+	}
+}
+
+////////////////////////////////////////////////////////////// impl
+
+func socketSwitch(on bool) int8 {
+	if on {
+		return SocketOn
+	}
+	return SocketOff
+}
@@ -1,23 +1,147 @@
 package def
 
+import (
+	"time"
+
+	"github.com/jumperzq86/jumper_conn/interf"
+)
+
+//时间字段为秒数，新代码建议使用 NewConnConfig 创建 ConnConfig
 type ConnOptions struct {
-	MaxMsgSize     int64
-	ReadTimeout    int64
-	WriteTimeout   int64
-	AsyncWriteSize int64
-	Side           int8
-
-	PongWait         int64
-	PingPeriod       int64
-	CloseGracePeriod int64
+	MaxMsgSize      int64
+	LimitTcpMsgSize bool //tcp 使用默认分帧方式时也按 MaxMsgSize 限制，默认只限制 ws，tcp 只受 def.MaxFrameLengthLimit 限制
+	ReadTimeout     int64
+	WriteTimeout    int64
+	AsyncWriteSize  int64
+	Side            int8
+
+	OverflowPolicy  int8  //AsyncWrite 队列满时的处理策略
+	OverflowTimeout int64 //OverflowBlockTimeout 时的等待时间
+
+	AsyncWriteBytes int64   //发送队列中未发送数据的字节数上限，0 表示只按条数限制
+	LaneSizes       []int64 //各优先级队列的容量，下标即优先级，为空时只有一个容量为 AsyncWriteSize 的队列
+	StarvationLimit int64   //低优先级队列连续被跳过该次数后优先发送一次，0 表示不做保护
+
+	PongWait          int64
+	PingPeriod        int64 //ws 发送 ping 的间隔秒数，0 表示不发送，必须小于 PongWait
+	CloseGracePeriod  int64
+	CloseFlushTimeout int64 //Handler 返回 def.CloseWith 时等待发送队列写出的秒数，0 表示使用 def.CloseFlushTimeout
+	ClientPing        bool  //ws 客户端也按 PingPeriod 发送 ping，用于检测服务端失效和计算往返时间
+
+	Decoder interf.Decoder //tcp 分帧方式，为 nil 时使用 4 字节大端长度头
+
+	WriteRate    int64          //出方向限速，字节/秒，0 表示不限速
+	WriteBurst   int64          //出方向突发字节数，0 时等于 WriteRate
+	WriteLimiter interf.Limiter //多个连接共享的出方向限速，为 nil 时不限制
+
+	ReadMsgRate     int64                             //入方向每秒消息数，0 表示不限制
+	ReadMsgBurst    int64                             //入方向突发消息数，0 时等于 ReadMsgRate
+	ReadByteRate    int64                             //入方向每秒字节数，0 表示不限制
+	ReadByteBurst   int64                             //入方向突发字节数，0 时等于 ReadByteRate
+	ReadLimitPolicy int8                              //超出入方向限制时的处理策略
+	ReadLimitHook   func(conn interf.Conn, err error) //超出入方向限制时回调，可用于记录或封禁对端
+
+	SlowQueuePercent  int64                             //发送队列占用达到该百分比
+	SlowQueueDuration int64                             //并持续该秒数时判定为慢消费者，0 表示不检测
+	SlowWriteTimeout  int64                             //一次写阻塞超过该秒数时判定为慢消费者，0 表示不检测
+	SlowConsumerEvict bool                              //判定为慢消费者时关闭连接，OnClose 收到 def.ErrSlowConsumerQueue 或 def.ErrSlowConsumerWrite
+	SlowConsumerHook  func(conn interf.Conn, err error) //判定为慢消费者时回调
+
+	WorkerPool       interf.WorkerPool //不为 nil 时 OnMessage 在共享协程池中执行，同一连接的消息保持顺序
+	InboundQueueSize int64             //使用协程池时每个连接待处理消息数上限，达到上限时暂停读取
+
+	Group interf.ConnGroup //不为 nil 时连接在 Run 时加入该组，关闭时移出，可用于批量调整同一服务的所有连接
+
+	InboundMiddlewares  []interf.InboundMiddleware  //使用同一 ConnOptions 的所有连接共享，按顺序执行
+	OutboundMiddlewares []interf.OutboundMiddleware //使用同一 ConnOptions 的所有连接共享，按顺序执行
+
+	PanicPolicy int8                                                //Handler 回调、ReadLimitHook、SlowConsumerHook 和 rpc 处理函数 panic 时的处理策略
+	PanicHook   func(conn interf.Conn, v interface{}, stack []byte) //Handler 回调 panic 时调用，为 nil 且 Handler 未实现 OnPanic 时打印堆栈
+
+	HandlerErrorHook func(conn interf.Conn, err error) //OnMessage 返回 def.NonFatal 包装的错误时调用，为 nil 时打印错误
+
+	HeartbeatInterval int64 //tcp 心跳间隔秒数，0 表示不主动发送心跳
+	HeartbeatMiss     int64 //连续该数量的间隔内没有读到任何数据时以 def.ErrHeartbeatTimeout 关闭连接
+	HeartbeatReply    bool  //不主动发送心跳时仍然回复对端的心跳
+
+	IdleTimeout int64 //读写两个方向都没有应用数据超过该秒数时以 def.ErrIdleTimeout 关闭连接，0 表示不检测
+
+	//以下 socket 选项应用于 tcp 连接和 ws 的底层连接，0 表示使用系统默认值
+	NoDelay         int8  //TCP_NODELAY
+	KeepAlive       int8  //SO_KEEPALIVE
+	KeepAlivePeriod int64 //keepalive 探测间隔秒数
+	ReadBuffer      int64 //SO_RCVBUF 字节数
+	WriteBuffer     int64 //SO_SNDBUF 字节数
+	Linger          int64 //SO_LINGER 秒数，小于 0 表示关闭时丢弃未发送的数据
+	UserTimeout     int64 //TCP_USER_TIMEOUT 毫秒数，只支持 linux
 }
 
-func (this *ConnOptions) CheckValid() error {
-	if this.AsyncWriteSize <= 0 {
-		return ErrInvalidConnParam
+//兼容旧的配置方式，时间字段按秒换算，UserTimeout 按毫秒换算
+func (this *ConnOptions) Config() *ConnConfig {
+	return &ConnConfig{
+		MaxMsgSize:          this.MaxMsgSize,
+		LimitTcpMsgSize:     this.LimitTcpMsgSize,
+		ReadTimeout:         seconds(this.ReadTimeout),
+		WriteTimeout:        seconds(this.WriteTimeout),
+		AsyncWriteSize:      this.AsyncWriteSize,
+		Side:                this.Side,
+		OverflowPolicy:      this.OverflowPolicy,
+		OverflowTimeout:     seconds(this.OverflowTimeout),
+		AsyncWriteBytes:     this.AsyncWriteBytes,
+		LaneSizes:           this.LaneSizes,
+		StarvationLimit:     this.StarvationLimit,
+		PongWait:            seconds(this.PongWait),
+		PingPeriod:          seconds(this.PingPeriod),
+		CloseGracePeriod:    seconds(this.CloseGracePeriod),
+		CloseFlushTimeout:   seconds(this.CloseFlushTimeout),
+		ClientPing:          this.ClientPing,
+		Decoder:             this.Decoder,
+		WriteRate:           this.WriteRate,
+		WriteBurst:          this.WriteBurst,
+		WriteLimiter:        this.WriteLimiter,
+		ReadMsgRate:         this.ReadMsgRate,
+		ReadMsgBurst:        this.ReadMsgBurst,
+		ReadByteRate:        this.ReadByteRate,
+		ReadByteBurst:       this.ReadByteBurst,
+		ReadLimitPolicy:     this.ReadLimitPolicy,
+		ReadLimitHook:       this.ReadLimitHook,
+		SlowQueuePercent:    this.SlowQueuePercent,
+		SlowQueueDuration:   seconds(this.SlowQueueDuration),
+		SlowWriteTimeout:    seconds(this.SlowWriteTimeout),
+		SlowConsumerEvict:   this.SlowConsumerEvict,
+		SlowConsumerHook:    this.SlowConsumerHook,
+		WorkerPool:          this.WorkerPool,
+		InboundQueueSize:    this.InboundQueueSize,
+		Group:               this.Group,
+		InboundMiddlewares:  this.InboundMiddlewares,
+		OutboundMiddlewares: this.OutboundMiddlewares,
+		PanicPolicy:         this.PanicPolicy,
+		PanicHook:           this.PanicHook,
+		HandlerErrorHook:    this.HandlerErrorHook,
+		HeartbeatInterval:   seconds(this.HeartbeatInterval),
+		HeartbeatMiss:       this.HeartbeatMiss,
+		HeartbeatReply:      this.HeartbeatReply,
+		IdleTimeout:         seconds(this.IdleTimeout),
+		NoDelay:             this.NoDelay,
+		KeepAlive:           this.KeepAlive,
+		KeepAlivePeriod:     seconds(this.KeepAlivePeriod),
+		ReadBuffer:          this.ReadBuffer,
+		WriteBuffer:         this.WriteBuffer,
+		Linger:              seconds(this.Linger),
+		UserTimeout:         time.Duration(this.UserTimeout) * time.Millisecond,
 	}
-	if this.PingPeriod != 0 && this.PingPeriod >= this.PongWait {
+}
+
+//与旧版本一致，不满足约束时返回 ErrInvalidConnParam 本身，调用方可以直接用 == 比较，详细信息见 ConnConfig.CheckValid
+func (this *ConnOptions) CheckValid() error {
+	if this.Config().CheckValid() != nil {
 		return ErrInvalidConnParam
 	}
 	return nil
 }
+
+////////////////////////////////////////////////////////////// impl
+
+func seconds(n int64) time.Duration {
+	return time.Duration(n) * time.Second
+}
@@ -0,0 +1,99 @@
+package def
+
+import (
+	"time"
+)
+
+type RateLimit struct {
+	Rate  int64 //0 表示不限制
+	Burst int64 //0 时等于 Rate
+}
+
+//运行时调整连接的选项，为 nil 的字段保持不变，修改从下一帧开始生效，已经在等待中的读取仍使用原来的设置
+//如 new(def.ConnUpdate).SetReadTimeout(5 * time.Second).SetMaxMsgSize(1024)
+type ConnUpdate struct {
+	ReadTimeout  *time.Duration //不能开启或关闭读超时
+	WriteTimeout *time.Duration
+	MaxMsgSize   *int64 //使用自定义 Decoder 的 tcp 连接不能调整，未开启 LimitTcpMsgSize 的 tcp 连接不受影响
+
+	WriteRate    *RateLimit
+	ReadMsgRate  *RateLimit
+	ReadByteRate *RateLimit
+
+	PingPeriod *time.Duration //只能调整间隔，不能开启或关闭 ping
+	PongWait   *time.Duration
+}
+
+func (this *ConnUpdate) SetReadTimeout(d time.Duration) *ConnUpdate {
+	this.ReadTimeout = &d
+	return this
+}
+
+func (this *ConnUpdate) SetWriteTimeout(d time.Duration) *ConnUpdate {
+	this.WriteTimeout = &d
+	return this
+}
+
+func (this *ConnUpdate) SetMaxMsgSize(size int64) *ConnUpdate {
+	this.MaxMsgSize = &size
+	return this
+}
+
+func (this *ConnUpdate) SetWriteRate(rate int64, burst int64) *ConnUpdate {
+	this.WriteRate = &RateLimit{Rate: rate, Burst: burst}
+	return this
+}
+
+func (this *ConnUpdate) SetReadMsgRate(rate int64, burst int64) *ConnUpdate {
+	this.ReadMsgRate = &RateLimit{Rate: rate, Burst: burst}
+	return this
+}
+
+func (this *ConnUpdate) SetReadByteRate(rate int64, burst int64) *ConnUpdate {
+	this.ReadByteRate = &RateLimit{Rate: rate, Burst: burst}
+	return this
+}
+
+func (this *ConnUpdate) SetPing(period time.Duration, pongWait time.Duration) *ConnUpdate {
+	this.PingPeriod = &period
+	this.PongWait = &pongWait
+	return this
+}
+
+//将修改应用到 cc 上并检查，cc 为连接当前配置的副本，返回错误时 cc 的内容不再可用
+func (this *ConnUpdate) Apply(cc *ConnConfig) error {
+	if this.ReadTimeout != nil {
+		if (cc.ReadTimeout == 0) != (*this.ReadTimeout == 0) {
+			return invalidConnParam("ReadTimeout", "can not be changed between 0 and non-zero on a live conn")
+		}
+		cc.ReadTimeout = *this.ReadTimeout
+	}
+	if this.WriteTimeout != nil {
+		cc.WriteTimeout = *this.WriteTimeout
+	}
+	if this.MaxMsgSize != nil {
+		if cc.Decoder != nil {
+			return invalidConnParam("MaxMsgSize", "can not be updated when Decoder is set")
+		}
+		cc.MaxMsgSize = *this.MaxMsgSize
+	}
+	if this.WriteRate != nil {
+		cc.WriteRate, cc.WriteBurst = this.WriteRate.Rate, this.WriteRate.Burst
+	}
+	if this.ReadMsgRate != nil {
+		cc.ReadMsgRate, cc.ReadMsgBurst = this.ReadMsgRate.Rate, this.ReadMsgRate.Burst
+	}
+	if this.ReadByteRate != nil {
+		cc.ReadByteRate, cc.ReadByteBurst = this.ReadByteRate.Rate, this.ReadByteRate.Burst
+	}
+	if this.PingPeriod != nil {
+		if (cc.PingPeriod == 0) != (*this.PingPeriod == 0) {
+			return invalidConnParam("PingPeriod", "can not be changed between 0 and non-zero on a live conn")
+		}
+		cc.PingPeriod = *this.PingPeriod
+	}
+	if this.PongWait != nil {
+		cc.PongWait = *this.PongWait
+	}
+	return cc.CheckValid()
+}
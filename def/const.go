@@ -9,3 +9,45 @@ const (
 	TcpHeadSize = 4
 )
 
+//tcp 心跳帧的内容，对应 PacketBinary 中类型为 0xffff 的消息，应用消息不能与其相同
+var (
+	HeartbeatPing = []byte{0xff, 0xff, 0x01}
+	HeartbeatPong = []byte{0xff, 0xff, 0x02}
+)
+
+//AsyncWrite 队列满时的处理策略
+const (
+	OverflowBlock        int8 = iota //阻塞直到有空间或连接关闭
+	OverflowBlockTimeout             //阻塞至多 OverflowTimeout 秒
+	OverflowDropNewest               //丢弃本次写入的数据
+	OverflowDropOldest               //丢弃队列中最早的数据，写入本次数据并返回 nil，被丢弃数据的 future 以 ErrWriteDropOldest 结束
+	OverflowError                    //直接返回错误
+	OverflowClose                    //关闭该慢速连接
+)
+
+//AsyncWrite 优先级，数值越小优先级越高
+const (
+	PriorityHigh int8 = iota
+	PriorityNormal
+	PriorityLow
+)
+
+//超出入方向限制时的处理策略
+const (
+	ReadLimitDelay int8 = iota //延迟读取下一条消息，依靠 tcp 流控降低对端发送速度
+	ReadLimitDrop              //丢弃超出限制的消息
+	ReadLimitClose             //关闭连接
+)
+
+//开关类 socket 选项的取值
+const (
+	SocketDefault int8 = iota //使用系统默认值
+	SocketOn
+	SocketOff
+)
+
+//Handler 回调 panic 时的处理策略
+const (
+	PanicClose    int8 = iota //以错误码为 ErrHandlerPanicCode 的错误关闭连接，可用 errors.Is(err, ErrHandlerPanic) 判断
+	PanicContinue             //丢弃正在处理的消息，继续读取
+)
@@ -0,0 +1,31 @@
+package def
+
+//MaxFrameLength 为 0 时帧总长度的上限，避免对端声明的长度导致分配过大的内存
+const MaxFrameLengthLimit = 1 << 30
+
+//参照 netty LengthFieldBasedFrameDecoder
+//帧总长度 = 长度字段值 + LengthAdjustment + LengthFieldOffset + LengthFieldLength
+//返回内容 = 帧中去除前 InitialBytesToStrip 个字节之后的部分
+type LengthFieldOptions struct {
+	MaxFrameLength      int64 //帧总长度上限（包含头部），0 表示使用 MaxFrameLengthLimit
+	LengthFieldOffset   int
+	LengthFieldLength   int //只支持 1/2/3/4/8
+	LengthAdjustment    int
+	InitialBytesToStrip int
+	LittleEndian        bool
+}
+
+func (this *LengthFieldOptions) CheckValid() error {
+	if this.MaxFrameLength < 0 || this.MaxFrameLength > MaxFrameLengthLimit || this.LengthFieldOffset < 0 || this.InitialBytesToStrip < 0 {
+		return ErrInvalidDecoderParam
+	}
+	switch this.LengthFieldLength {
+	case 1, 2, 3, 4, 8:
+	default:
+		return ErrInvalidDecoderParam
+	}
+	if this.MaxFrameLength > 0 && int64(this.LengthFieldOffset+this.LengthFieldLength) > this.MaxFrameLength {
+		return ErrInvalidDecoderParam
+	}
+	return nil
+}
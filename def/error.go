@@ -1,25 +1,61 @@
 package def
 
-
 const (
-	ErrConnClosedCode           = 11011
-	ErrConnUnexpectedClosedCode = 11012
-	ErrInvalidConnParamCode     = 11013
+	ErrConnClosedCode               = 11011
+	ErrConnUnexpectedClosedCode     = 11012
+	ErrInvalidConnParamCode         = 11013
+	ErrFrameTooLargeCode            = 11014
+	ErrInvalidFrameLengthCode       = 11015
+	ErrInvalidDecoderParamCode      = 11016
+	ErrWriteQueueTimeoutCode        = 11017
+	ErrWriteDropNewestCode          = 11018
+	ErrWriteDropOldestCode          = 11019
+	ErrWriteQueueFullCode           = 11020
+	ErrSlowConnClosedCode           = 11021
+	ErrConnClosingCode              = 11022
+	ErrCloseTimeoutCode             = 11023
+	ErrWriteDroppedOnCloseCode      = 11024
+	ErrReadLimitExceededCode        = 11025
+	ErrSlowConsumerQueueCode        = 11026
+	ErrSlowConsumerWriteCode        = 11027
+	ErrWorkerPoolClosedCode         = 11028
+	ErrInvalidPoolParamCode         = 11029
+	ErrHandlerPanicCode             = 11030
+	ErrRouterNoTransformCode        = 11031
+	ErrUnknownMsgTypeCode           = 11032
+	ErrInvalidRpcParamCode          = 11033
+	ErrInvalidRpcFrameCode          = 11034
+	ErrRpcMethodNotFoundCode        = 11035
+	ErrRpcRemoteCode                = 11036
+	ErrHeartbeatTimeoutCode         = 11037
+	ErrIdleTimeoutCode              = 11038
+	ErrInvalidConfigCode            = 11039
+	ErrReconfigureUnsupportedCode   = 11040
+	ErrRpcBusyCode                  = 11041
+	ErrWriteDroppedByMiddlewareCode = 11042
+	ErrWriteExceedsBudgetCode       = 11044
 
 	ErrGetExternalIpCode = 12011
 	ErrGetMacAddrCode    = 12012
+	ErrSocketOptionCode  = 12013
 )
 
-type Error struct{
-	Code int32
+type Error struct {
+	Code    int32
 	Message string
 }
 
-func (this *Error) Error() string{
+func (this *Error) Error() string {
 	return this.Message
 }
 
-func New(code int32, message string) *Error{
+//错误码相同即视为同一错误，使 errors.Is(err, ErrInvalidConnParam) 可以匹配带有详细信息的错误
+func (this *Error) Is(target error) bool {
+	t, ok := target.(*Error)
+	return ok && t.Code == this.Code
+}
+
+func New(code int32, message string) *Error {
 	return &Error{
 		Code:    code,
 		Message: message,
@@ -27,10 +63,40 @@ func New(code int32, message string) *Error{
 }
 
 var (
-	ErrConnClosed           = New(ErrConnClosedCode, "conn is closed.")
-	ErrConnUnexpectedClosed = New(ErrConnUnexpectedClosedCode, "conn is unexpected closed.")
-	ErrInvalidConnParam     = New(ErrInvalidConnParamCode, "create conn invalid param.")
+	ErrConnClosed               = New(ErrConnClosedCode, "conn is closed.")
+	ErrConnUnexpectedClosed     = New(ErrConnUnexpectedClosedCode, "conn is unexpected closed.")
+	ErrInvalidConnParam         = New(ErrInvalidConnParamCode, "create conn invalid param.")
+	ErrFrameTooLarge            = New(ErrFrameTooLargeCode, "frame is too large.")
+	ErrInvalidFrameLength       = New(ErrInvalidFrameLengthCode, "invalid frame length.")
+	ErrInvalidDecoderParam      = New(ErrInvalidDecoderParamCode, "create decoder invalid param.")
+	ErrWriteQueueTimeout        = New(ErrWriteQueueTimeoutCode, "wait for write queue timeout.")
+	ErrWriteDropNewest          = New(ErrWriteDropNewestCode, "write queue is full, newest data dropped.")
+	ErrWriteDropOldest          = New(ErrWriteDropOldestCode, "write queue is full, oldest data dropped.")
+	ErrWriteQueueFull           = New(ErrWriteQueueFullCode, "write queue is full.")
+	ErrSlowConnClosed           = New(ErrSlowConnClosedCode, "write queue is full, slow conn closed.")
+	ErrConnClosing              = New(ErrConnClosingCode, "conn is closing.")
+	ErrCloseTimeout             = New(ErrCloseTimeoutCode, "close gracefully timeout, pending data dropped.")
+	ErrWriteDroppedOnClose      = New(ErrWriteDroppedOnCloseCode, "conn closed before data written.")
+	ErrReadLimitExceeded        = New(ErrReadLimitExceededCode, "inbound rate limit exceeded.")
+	ErrSlowConsumerQueue        = New(ErrSlowConsumerQueueCode, "slow consumer, write queue stays full.")
+	ErrSlowConsumerWrite        = New(ErrSlowConsumerWriteCode, "slow consumer, write blocked too long.")
+	ErrWorkerPoolClosed         = New(ErrWorkerPoolClosedCode, "worker pool is closed.")
+	ErrInvalidPoolParam         = New(ErrInvalidPoolParamCode, "create worker pool invalid param.")
+	ErrHandlerPanic             = New(ErrHandlerPanicCode, "handler panic.")
+	ErrRouterNoTransform        = New(ErrRouterNoTransformCode, "router transform is nil.")
+	ErrUnknownMsgType           = New(ErrUnknownMsgTypeCode, "unknown message type.")
+	ErrInvalidRpcParam          = New(ErrInvalidRpcParamCode, "create rpc invalid param.")
+	ErrInvalidRpcFrame          = New(ErrInvalidRpcFrameCode, "invalid rpc frame.")
+	ErrRpcMethodNotFound        = New(ErrRpcMethodNotFoundCode, "rpc method not found.")
+	ErrHeartbeatTimeout         = New(ErrHeartbeatTimeoutCode, "heartbeat timeout.")
+	ErrIdleTimeout              = New(ErrIdleTimeoutCode, "idle timeout.")
+	ErrInvalidConfig            = New(ErrInvalidConfigCode, "invalid config.")
+	ErrReconfigureUnsupported   = New(ErrReconfigureUnsupportedCode, "conn does not support reconfigure.")
+	ErrRpcBusy                  = New(ErrRpcBusyCode, "rpc too many concurrent requests.")
+	ErrWriteDroppedByMiddleware = New(ErrWriteDroppedByMiddlewareCode, "data dropped by outbound middleware.")
+	ErrWriteExceedsBudget       = New(ErrWriteExceedsBudgetCode, "data is larger than the write memory budget.")
 
 	ErrGetExternalIp = New(ErrGetExternalIpCode, "get external ip failed.")
 	ErrGetMacAddr    = New(ErrGetMacAddrCode, "get mac addr failed.")
+	ErrSocketOption  = New(ErrSocketOptionCode, "socket option unsupported.")
 )
@@ -0,0 +1,50 @@
+package def
+
+import (
+	"errors"
+)
+
+//Handler 返回错误时连接的处理方式
+const (
+	ErrorActionAbort    int8 = iota //立即关闭连接，未包装的错误都按此处理
+	ErrorActionContinue             //上报错误，继续读取
+	ErrorActionClose                //发送完队列中的数据后关闭连接，ws 在 close 消息中携带错误信息，对端 wsConn 的 OnClose 收到以该信息为内容、错误码为 ErrConnClosedCode 的错误
+)
+
+//包装 Handler 返回的错误，指定连接的处理方式
+type HandlerError struct {
+	Action int8
+	Err    error
+}
+
+func (this *HandlerError) Error() string {
+	return this.Err.Error()
+}
+
+func (this *HandlerError) Unwrap() error {
+	return this.Err
+}
+
+//上报错误，连接继续读取
+func NonFatal(err error) error {
+	return &HandlerError{Action: ErrorActionContinue, Err: err}
+}
+
+//发送完队列中的数据后关闭连接，最多等待 CloseFlushTimeout，OnClose 收到 err
+func CloseWith(err error) error {
+	return &HandlerError{Action: ErrorActionClose, Err: err}
+}
+
+//立即关闭连接，OnClose 收到 err
+func Fatal(err error) error {
+	return &HandlerError{Action: ErrorActionAbort, Err: err}
+}
+
+//返回错误的处理方式和包装前的错误
+func ParseHandlerError(err error) (int8, error) {
+	var he *HandlerError
+	if errors.As(err, &he) {
+		return he.Action, he.Err
+	}
+	return ErrorActionAbort, err
+}
@@ -0,0 +1,11 @@
+package def
+
+import (
+	jti "github.com/jumperzq86/jumper_transform/interf"
+)
+
+type RouterOptions struct {
+	Transform jti.Transform //为 nil 时使用 Handler.Init 传入的 transform
+	TcpHead   bool          //Send 时添加 TcpHeadSize 字节大端长度头，与 tcp 默认的分帧方式对应
+	AsyncSend bool          //Send 使用 AsyncWrite，否则使用 Write
+}
@@ -0,0 +1,14 @@
+package def
+
+type RpcOptions struct {
+	TcpHead       bool  //发送时添加 TcpHeadSize 字节大端长度头，与 tcp 默认的分帧方式对应
+	CallTimeout   int64 //ctx 没有截止时间时 Call 的超时秒数，0 表示只依赖 ctx
+	MaxConcurrent int64 //每个连接同时处理的请求数上限，超出时以 ErrRpcBusy 响应，0 表示使用 RpcMaxConcurrent
+}
+
+func (this *RpcOptions) CheckValid() error {
+	if this.CallTimeout < 0 || this.MaxConcurrent < 0 {
+		return ErrInvalidRpcParam
+	}
+	return nil
+}
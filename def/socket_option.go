@@ -0,0 +1,13 @@
+package def
+
+//连接上实际生效的 socket 选项
+//note: linux 上 ReadBuffer 和 WriteBuffer 为内核加倍之后的值
+type SocketOptions struct {
+	NoDelay         bool
+	KeepAlive       bool
+	KeepAlivePeriod int64 //秒
+	ReadBuffer      int64
+	WriteBuffer     int64
+	Linger          bool  //是否开启 SO_LINGER
+	UserTimeout     int64 //毫秒
+}
@@ -37,15 +37,13 @@ func main() {
 		go func(c net.Conn) {
 			var h Handler
 
-			tcpOp := def.ConnOptions{
-				MaxMsgSize:     def.MaxMsgSize,
-				ReadTimeout:    def.ReadTimeout,
-				WriteTimeout:   def.WriteTimeout,
-				AsyncWriteSize: def.AsyncWriteSize,
-				Side:           def.ServerSide,
+			tcpCc, err := def.NewConnConfig(def.WithSide(def.ServerSide))
+			if err != nil {
+				fmt.Printf("new conn config failed. err: %s\n", err)
+				return
 			}
 
-			jconn, err := jumper_conn.NewtcpConn(c, &tcpOp, &h)
+			jconn, err := jumper_conn.NewtcpConnWithConfig(c, tcpCc, &h)
 			if err != nil {
 				fmt.Printf("new tcp conn failed. err: %s\n", err)
 				return
@@ -5,4 +5,5 @@ go 1.16
 require (
 	github.com/gorilla/websocket v1.4.2
 	github.com/jumperzq86/jumper_transform v0.0.0-20210721063713-ec3a45f6963b
+	gopkg.in/yaml.v3 v3.0.1
 )
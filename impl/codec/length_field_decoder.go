@@ -0,0 +1,104 @@
+package codec
+
+import (
+	"encoding/binary"
+	"io"
+
+	"github.com/jumperzq86/jumper_conn/interf"
+
+	"github.com/jumperzq86/jumper_conn/def"
+)
+
+type lengthFieldDecoder struct {
+	lo        def.LengthFieldOptions
+	headSize  int
+	byteOrder binary.ByteOrder
+}
+
+func CreatelengthFieldDecoder(lo *def.LengthFieldOptions) (interf.Decoder, error) {
+
+	err := lo.CheckValid()
+	if err != nil {
+		return nil, err
+	}
+
+	rd := &lengthFieldDecoder{
+		lo:        *lo,
+		headSize:  lo.LengthFieldOffset + lo.LengthFieldLength,
+		byteOrder: binary.BigEndian,
+	}
+	if lo.LittleEndian {
+		rd.byteOrder = binary.LittleEndian
+	}
+
+	return rd, nil
+}
+
+//默认帧格式：4 字节大端长度头 + 内容，长度头中不包含头部本身
+func CreatedefaultDecoder(maxMsgSize int64) interf.Decoder {
+	lo := def.LengthFieldOptions{
+		LengthFieldOffset:   0,
+		LengthFieldLength:   def.TcpHeadSize,
+		LengthAdjustment:    0,
+		InitialBytesToStrip: def.TcpHeadSize,
+	}
+	if maxMsgSize > 0 && maxMsgSize <= def.MaxFrameLengthLimit-def.TcpHeadSize {
+		lo.MaxFrameLength = maxMsgSize + def.TcpHeadSize
+	}
+
+	decoder, _ := CreatelengthFieldDecoder(&lo)
+	return decoder
+}
+
+func (this *lengthFieldDecoder) Decode(r io.Reader) ([]byte, error) {
+	head := make([]byte, this.headSize)
+	_, err := io.ReadFull(r, head)
+	if err != nil {
+		return nil, err
+	}
+
+	length := this.getLength(head[this.lo.LengthFieldOffset:])
+	frameLength := int64(length) + int64(this.lo.LengthAdjustment) + int64(this.headSize)
+	if length > uint64(1<<62) || frameLength < int64(this.headSize) {
+		return nil, def.ErrInvalidFrameLength
+	}
+	maxFrameLength := this.lo.MaxFrameLength
+	if maxFrameLength == 0 {
+		maxFrameLength = def.MaxFrameLengthLimit
+	}
+	if frameLength > maxFrameLength {
+		return nil, def.ErrFrameTooLarge
+	}
+	if int64(this.lo.InitialBytesToStrip) > frameLength {
+		return nil, def.ErrInvalidFrameLength
+	}
+
+	frame := make([]byte, frameLength)
+	copy(frame, head)
+	_, err = io.ReadFull(r, frame[this.headSize:])
+	if err != nil {
+		return nil, err
+	}
+
+	return frame[this.lo.InitialBytesToStrip:], nil
+}
+
+////////////////////////////////////////////////////////////// impl
+
+func (this *lengthFieldDecoder) getLength(field []byte) uint64 {
+	switch this.lo.LengthFieldLength {
+	case 1:
+		return uint64(field[0])
+	case 2:
+		return uint64(this.byteOrder.Uint16(field))
+	case 3:
+		if this.lo.LittleEndian {
+			return uint64(field[0]) | uint64(field[1])<<8 | uint64(field[2])<<16
+		}
+		return uint64(field[2]) | uint64(field[1])<<8 | uint64(field[0])<<16
+	case 4:
+		return uint64(this.byteOrder.Uint32(field))
+	default:
+		return this.byteOrder.Uint64(field)
+	}
+}
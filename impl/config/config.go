@@ -0,0 +1,252 @@
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strconv"
+	"strings"
+
+	"gopkg.in/yaml.v3"
+
+	"github.com/jumperzq86/jumper_conn/def"
+)
+
+//配置文件格式：
+//
+//	conn:            所有服务共享的连接选项
+//	servers:
+//	  - name: game   服务名，不能重复
+//	    type: tcp    tcp 或 ws
+//	    addr: :8080
+//	    path: /ws    ws 的 http 路径
+//	    conn:        覆盖共享连接选项中的部分字段
+//
+//时间可以写成 "1m30s" 或秒数，未出现的字段使用 def.DefaultConnConfig 的值，不认识的字段视为错误
+type fileConfig struct {
+	Conn    json.RawMessage `json:"conn"`
+	Servers []*fileServer   `json:"servers"`
+}
+
+type fileServer struct {
+	Name string          `json:"name"`
+	Type string          `json:"type"`
+	Addr string          `json:"addr"`
+	Path string          `json:"path"`
+	Conn json.RawMessage `json:"conn"`
+}
+
+//按扩展名 .yaml、.yml、.json 确定格式
+func LoadConfig(path string, envPrefix string) (*def.Config, error) {
+	var format string
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".yaml", ".yml":
+		format = def.ConfigYaml
+	case ".json":
+		format = def.ConfigJson
+	default:
+		return nil, invalidConfig("unknown format of file %s", path)
+	}
+
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+	return ParseConfig(data, format, envPrefix)
+}
+
+//envPrefix 不为空时使用环境变量覆盖文件中的值，环境变量名为：
+//
+//	<envPrefix>_<FIELD>          覆盖共享的连接选项，如 JC_READ_TIMEOUT=30s
+//	<envPrefix>_<SERVER>_<FIELD> 覆盖服务的定义和连接选项，如 JC_GAME_ADDR=:9000、JC_GAME_MAX_MSG_SIZE=4096
+//
+//FIELD 和 SERVER 为文件中的字段名和服务名，转为大写并将字母和数字以外的字符替换为 _
+//服务连接选项的优先级从低到高为：默认值、共享的连接选项、服务的连接选项、共享的环境变量、服务的环境变量
+func ParseConfig(data []byte, format string, envPrefix string) (*def.Config, error) {
+	var err error
+	switch format {
+	case def.ConfigYaml:
+		data, err = yamlToJson(data)
+		if err != nil {
+			return nil, invalidConfig("%s", err)
+		}
+	case def.ConfigJson:
+	default:
+		return nil, invalidConfig("unknown format %s", format)
+	}
+
+	var fc fileConfig
+	err = decodeStrict(data, &fc)
+	if err != nil {
+		return nil, invalidConfig("%s", err)
+	}
+
+	//sharedFile 只包含文件中的值，服务的连接选项在其基础上覆盖后再应用环境变量
+	sharedFile := defaultFileConn()
+	err = decodeStrict(fc.Conn, sharedFile)
+	if err != nil {
+		return nil, invalidConfig("conn: %s", err)
+	}
+	var sharedPrefix string
+	if envPrefix != "" {
+		sharedPrefix = envName(envPrefix)
+	}
+	shared := sharedFile.clone()
+	err = overrideEnv(shared, sharedPrefix)
+	if err != nil {
+		return nil, err
+	}
+
+	rc := &def.Config{}
+	rc.Conn, err = shared.toConnConfig("conn")
+	if err != nil {
+		return nil, err
+	}
+
+	names := make(map[string]bool)
+	for i, fs := range fc.Servers {
+		path := fmt.Sprintf("servers[%d]", i)
+		if fs.Name == "" {
+			return nil, invalidConfig("%s.name must not be empty", path)
+		}
+		if names[fs.Name] {
+			return nil, invalidConfig("%s.name %q is duplicated", path, fs.Name)
+		}
+		names[fs.Name] = true
+
+		var prefix string
+		if envPrefix != "" {
+			prefix = envName(envPrefix, fs.Name)
+		}
+		err = overrideEnv(fs, prefix)
+		if err != nil {
+			return nil, err
+		}
+		fcn := sharedFile.clone()
+		err = decodeStrict(fs.Conn, fcn)
+		if err != nil {
+			return nil, invalidConfig("%s.conn: %s", path, err)
+		}
+		err = overrideEnv(fcn, sharedPrefix)
+		if err != nil {
+			return nil, err
+		}
+		err = overrideEnv(fcn, prefix)
+		if err != nil {
+			return nil, err
+		}
+
+		server, err := fs.toServerConfig(path)
+		if err != nil {
+			return nil, err
+		}
+		server.Conn, err = fcn.toConnConfig(path + ".conn")
+		if err != nil {
+			return nil, err
+		}
+		rc.Servers = append(rc.Servers, server)
+	}
+	return rc, nil
+}
+
+////////////////////////////////////////////////////////////// impl
+
+func (this *fileServer) toServerConfig(path string) (*def.ServerConfig, error) {
+	if this.Type != def.ServerTcp && this.Type != def.ServerWs {
+		return nil, invalidConfig("%s.type must be %s or %s", path, def.ServerTcp, def.ServerWs)
+	}
+	_, _, err := net.SplitHostPort(this.Addr)
+	if err != nil {
+		return nil, invalidConfig("%s.addr: %s", path, err)
+	}
+
+	rc := &def.ServerConfig{
+		Name: this.Name,
+		Type: this.Type,
+		Addr: this.Addr,
+		Path: this.Path,
+	}
+	if this.Type == def.ServerTcp {
+		if this.Path != "" {
+			return nil, invalidConfig("%s.path must be empty for tcp server", path)
+		}
+		return rc, nil
+	}
+	if rc.Path == "" {
+		rc.Path = "/"
+	}
+	if !strings.HasPrefix(rc.Path, "/") {
+		return nil, invalidConfig("%s.path must start with /", path)
+	}
+	return rc, nil
+}
+
+func yamlToJson(data []byte) ([]byte, error) {
+	var v interface{}
+	err := yaml.Unmarshal(data, &v)
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(v)
+}
+
+//为空时保持 v 不变，不认识的字段视为错误
+func decodeStrict(data []byte, v interface{}) error {
+	if len(data) == 0 || string(data) == "null" {
+		return nil
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.DisallowUnknownFields()
+	return decoder.Decode(v)
+}
+
+//prefix 为空时不覆盖，name 和子结构不能被覆盖
+//note: 环境变量的值先按 json 解析，失败时作为字符串解析，因此字符串不需要加引号
+func overrideEnv(v interface{}, prefix string) error {
+	if prefix == "" {
+		return nil
+	}
+
+	rv := reflect.ValueOf(v).Elem()
+	rt := rv.Type()
+	for i := 0; i < rt.NumField(); i++ {
+		tag := rt.Field(i).Tag.Get("json")
+		if tag == "" || tag == "name" || rt.Field(i).Type == reflect.TypeOf(json.RawMessage(nil)) {
+			continue
+		}
+		name := prefix + "_" + envName(tag)
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			continue
+		}
+
+		field := rv.Field(i).Addr().Interface()
+		err := json.Unmarshal([]byte(value), field)
+		if err != nil {
+			err = json.Unmarshal([]byte(strconv.Quote(value)), field)
+		}
+		if err != nil {
+			return invalidConfig("env %s: %s", name, err)
+		}
+	}
+	return nil
+}
+
+func envName(parts ...string) string {
+	name := strings.ToUpper(strings.Join(parts, "_"))
+	return strings.Map(func(r rune) rune {
+		if (r >= 'A' && r <= 'Z') || (r >= '0' && r <= '9') {
+			return r
+		}
+		return '_'
+	}, name)
+}
+
+func invalidConfig(format string, args ...interface{}) error {
+	message := strings.TrimSuffix(fmt.Sprintf(format, args...), ".")
+	return def.New(def.ErrInvalidConfigCode, "invalid config: "+message+".")
+}
@@ -0,0 +1,243 @@
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"time"
+
+	"github.com/jumperzq86/jumper_conn/def"
+)
+
+//配置文件中的连接选项，字段对应 def.ConnConfig 中可以写在文件中的部分
+type fileConn struct {
+	MaxMsgSize      int64    `json:"max_msg_size"`
+	LimitTcpMsgSize bool     `json:"limit_tcp_msg_size"`
+	ReadTimeout     duration `json:"read_timeout"`
+	WriteTimeout    duration `json:"write_timeout"`
+	AsyncWriteSize  int64    `json:"async_write_size"`
+	Side            string   `json:"side"`
+
+	OverflowPolicy  string   `json:"overflow_policy"`
+	OverflowTimeout duration `json:"overflow_timeout"`
+
+	AsyncWriteBytes int64   `json:"async_write_bytes"`
+	LaneSizes       []int64 `json:"lane_sizes"`
+	StarvationLimit int64   `json:"starvation_limit"`
+
+	PongWait          duration `json:"pong_wait"`
+	PingPeriod        duration `json:"ping_period"`
+	CloseGracePeriod  duration `json:"close_grace_period"`
+	CloseFlushTimeout duration `json:"close_flush_timeout"`
+	ClientPing        bool     `json:"client_ping"`
+
+	WriteRate  int64 `json:"write_rate"`
+	WriteBurst int64 `json:"write_burst"`
+
+	ReadMsgRate     int64  `json:"read_msg_rate"`
+	ReadMsgBurst    int64  `json:"read_msg_burst"`
+	ReadByteRate    int64  `json:"read_byte_rate"`
+	ReadByteBurst   int64  `json:"read_byte_burst"`
+	ReadLimitPolicy string `json:"read_limit_policy"`
+
+	SlowQueuePercent  int64    `json:"slow_queue_percent"`
+	SlowQueueDuration duration `json:"slow_queue_duration"`
+	SlowWriteTimeout  duration `json:"slow_write_timeout"`
+	SlowConsumerEvict bool     `json:"slow_consumer_evict"`
+
+	InboundQueueSize int64 `json:"inbound_queue_size"`
+
+	PanicPolicy string `json:"panic_policy"`
+
+	HeartbeatInterval duration `json:"heartbeat_interval"`
+	HeartbeatMiss     int64    `json:"heartbeat_miss"`
+	HeartbeatReply    bool     `json:"heartbeat_reply"`
+
+	IdleTimeout duration `json:"idle_timeout"`
+
+	NoDelay         socketSwitch `json:"no_delay"`
+	KeepAlive       socketSwitch `json:"keep_alive"`
+	KeepAlivePeriod duration     `json:"keep_alive_period"`
+	ReadBuffer      int64        `json:"read_buffer"`
+	WriteBuffer     int64        `json:"write_buffer"`
+	Linger          duration     `json:"linger"`
+	UserTimeout     duration     `json:"user_timeout"`
+}
+
+//策略类字段在文件中使用名称，为空时使用默认值
+var (
+	sides = map[string]int8{
+		"server": def.ServerSide,
+		"client": def.ClientSide,
+	}
+	overflowPolicies = map[string]int8{
+		"block":         def.OverflowBlock,
+		"block_timeout": def.OverflowBlockTimeout,
+		"drop_newest":   def.OverflowDropNewest,
+		"drop_oldest":   def.OverflowDropOldest,
+		"error":         def.OverflowError,
+		"close":         def.OverflowClose,
+	}
+	readLimitPolicies = map[string]int8{
+		"delay": def.ReadLimitDelay,
+		"drop":  def.ReadLimitDrop,
+		"close": def.ReadLimitClose,
+	}
+	panicPolicies = map[string]int8{
+		"close":    def.PanicClose,
+		"continue": def.PanicContinue,
+	}
+)
+
+//默认值与 def.DefaultConnConfig 相同
+func defaultFileConn() *fileConn {
+	cc := def.DefaultConnConfig()
+	return &fileConn{
+		MaxMsgSize:        cc.MaxMsgSize,
+		ReadTimeout:       duration(cc.ReadTimeout),
+		WriteTimeout:      duration(cc.WriteTimeout),
+		AsyncWriteSize:    cc.AsyncWriteSize,
+		PongWait:          duration(cc.PongWait),
+		PingPeriod:        duration(cc.PingPeriod),
+		CloseGracePeriod:  duration(cc.CloseGracePeriod),
+		CloseFlushTimeout: duration(cc.CloseFlushTimeout),
+	}
+}
+
+func (this *fileConn) clone() *fileConn {
+	rc := *this
+	rc.LaneSizes = append([]int64(nil), this.LaneSizes...)
+	return &rc
+}
+
+//path 为该选项在配置文件中的位置，用于错误信息
+func (this *fileConn) toConnConfig(path string) (*def.ConnConfig, error) {
+	cc := &def.ConnConfig{
+		MaxMsgSize:        this.MaxMsgSize,
+		LimitTcpMsgSize:   this.LimitTcpMsgSize,
+		ReadTimeout:       time.Duration(this.ReadTimeout),
+		WriteTimeout:      time.Duration(this.WriteTimeout),
+		AsyncWriteSize:    this.AsyncWriteSize,
+		OverflowTimeout:   time.Duration(this.OverflowTimeout),
+		AsyncWriteBytes:   this.AsyncWriteBytes,
+		LaneSizes:         this.LaneSizes,
+		StarvationLimit:   this.StarvationLimit,
+		PongWait:          time.Duration(this.PongWait),
+		PingPeriod:        time.Duration(this.PingPeriod),
+		CloseGracePeriod:  time.Duration(this.CloseGracePeriod),
+		CloseFlushTimeout: time.Duration(this.CloseFlushTimeout),
+		ClientPing:        this.ClientPing,
+		WriteRate:         this.WriteRate,
+		WriteBurst:        this.WriteBurst,
+		ReadMsgRate:       this.ReadMsgRate,
+		ReadMsgBurst:      this.ReadMsgBurst,
+		ReadByteRate:      this.ReadByteRate,
+		ReadByteBurst:     this.ReadByteBurst,
+		SlowQueuePercent:  this.SlowQueuePercent,
+		SlowQueueDuration: time.Duration(this.SlowQueueDuration),
+		SlowWriteTimeout:  time.Duration(this.SlowWriteTimeout),
+		SlowConsumerEvict: this.SlowConsumerEvict,
+		InboundQueueSize:  this.InboundQueueSize,
+		HeartbeatInterval: time.Duration(this.HeartbeatInterval),
+		HeartbeatMiss:     this.HeartbeatMiss,
+		HeartbeatReply:    this.HeartbeatReply,
+		IdleTimeout:       time.Duration(this.IdleTimeout),
+		NoDelay:           int8(this.NoDelay),
+		KeepAlive:         int8(this.KeepAlive),
+		KeepAlivePeriod:   time.Duration(this.KeepAlivePeriod),
+		ReadBuffer:        this.ReadBuffer,
+		WriteBuffer:       this.WriteBuffer,
+		Linger:            time.Duration(this.Linger),
+		UserTimeout:       time.Duration(this.UserTimeout),
+	}
+
+	var err error
+	cc.Side, err = lookup(sides, this.Side, path+".side")
+	if err != nil {
+		return nil, err
+	}
+	cc.OverflowPolicy, err = lookup(overflowPolicies, this.OverflowPolicy, path+".overflow_policy")
+	if err != nil {
+		return nil, err
+	}
+	cc.ReadLimitPolicy, err = lookup(readLimitPolicies, this.ReadLimitPolicy, path+".read_limit_policy")
+	if err != nil {
+		return nil, err
+	}
+	cc.PanicPolicy, err = lookup(panicPolicies, this.PanicPolicy, path+".panic_policy")
+	if err != nil {
+		return nil, err
+	}
+
+	err = cc.CheckValid()
+	if err != nil {
+		return nil, invalidConfig("%s: %s", path, err)
+	}
+	return cc, nil
+}
+
+////////////////////////////////////////////////////////////// impl
+
+func lookup(names map[string]int8, name string, path string) (int8, error) {
+	if name == "" {
+		return 0, nil
+	}
+	v, ok := names[name]
+	if !ok {
+		return 0, invalidConfig("%s: unknown value %q", path, name)
+	}
+	return v, nil
+}
+
+//文件中的时间可以是 "1m30s" 形式的字符串，也可以是秒数
+type duration time.Duration
+
+func (this *duration) UnmarshalJSON(data []byte) error {
+	var s string
+	if json.Unmarshal(data, &s) == nil {
+		d, err := time.ParseDuration(s)
+		if err != nil {
+			return err
+		}
+		*this = duration(d)
+		return nil
+	}
+
+	var n float64
+	err := json.Unmarshal(data, &n)
+	if err != nil {
+		return err
+	}
+	*this = duration(n * float64(time.Second))
+	return nil
+}
+
+//文件中的开关类 socket 选项可以是 true/false，也可以是 "default"、"on"、"off"
+type socketSwitch int8
+
+func (this *socketSwitch) UnmarshalJSON(data []byte) error {
+	var on bool
+	if json.Unmarshal(data, &on) == nil {
+		*this = socketSwitch(def.SocketOff)
+		if on {
+			*this = socketSwitch(def.SocketOn)
+		}
+		return nil
+	}
+
+	var s string
+	err := json.Unmarshal(data, &s)
+	if err != nil {
+		return err
+	}
+	switch s {
+	case "default":
+		*this = socketSwitch(def.SocketDefault)
+	case "on":
+		*this = socketSwitch(def.SocketOn)
+	case "off":
+		*this = socketSwitch(def.SocketOff)
+	default:
+		return fmt.Errorf("unknown socket switch %q", s)
+	}
+	return nil
+}
@@ -0,0 +1,87 @@
+package conn
+
+import (
+	"sync/atomic"
+
+	"github.com/jumperzq86/jumper_conn/interf"
+
+	"github.com/jumperzq86/jumper_conn/def"
+)
+
+//将同一连接的消息交给共享的协程池处理
+//每个连接同一时刻最多占用一个协程，从而保证消息顺序
+type dispatcher struct {
+	scheduled int32
+
+	pool      interf.WorkerPool
+	inbound   chan []byte
+	closeChan <-chan struct{}
+	handle    func(data []byte) error
+	closeFunc func(err error)
+}
+
+func newDispatcher(cc *def.ConnConfig, closeChan <-chan struct{}, handle func(data []byte) error, closeFunc func(err error)) *dispatcher {
+	if cc.WorkerPool == nil {
+		return nil
+	}
+
+	return &dispatcher{
+		pool:      cc.WorkerPool,
+		inbound:   make(chan []byte, cc.InboundQueueSize),
+		closeChan: closeChan,
+		handle:    handle,
+		closeFunc: closeFunc,
+	}
+}
+
+//待处理消息达到上限时阻塞，read 协程随之暂停读取
+func (this *dispatcher) dispatch(data []byte) error {
+	select {
+	case this.inbound <- data:
+	case <-this.closeChan:
+		return def.ErrConnClosed
+	}
+
+	this.schedule()
+	return nil
+}
+
+////////////////////////////////////////////////////////////// impl
+
+func (this *dispatcher) schedule() {
+	if !atomic.CompareAndSwapInt32(&this.scheduled, 0, 1) {
+		return
+	}
+
+	err := this.pool.Submit(this.drain)
+	if err != nil {
+		atomic.StoreInt32(&this.scheduled, 0)
+		this.closeFunc(err)
+	}
+}
+
+//在协程池中依次处理该连接的消息，处理完后释放协程
+func (this *dispatcher) drain() {
+	for {
+		select {
+		case <-this.closeChan:
+			return
+		default:
+		}
+
+		select {
+		case data := <-this.inbound:
+			err := this.handle(data)
+			if err != nil {
+				this.closeFunc(err)
+				return
+			}
+		default:
+			atomic.StoreInt32(&this.scheduled, 0)
+			//释放之后又有新消息且没有被其他协程调度时继续处理
+			if len(this.inbound) == 0 || !atomic.CompareAndSwapInt32(&this.scheduled, 0, 1) {
+				return
+			}
+		}
+	}
+}
@@ -0,0 +1,56 @@
+package conn
+
+import (
+	"fmt"
+	"sync/atomic"
+	"time"
+
+	"github.com/jumperzq86/jumper_conn/interf"
+
+	"github.com/jumperzq86/jumper_conn/def"
+)
+
+//按 Handler 返回错误的处理方式执行，返回需要立即关闭连接的错误
+func handleError(conn interf.Conn, cc *def.ConnConfig, err error, closeGracefully func(timeout time.Duration, reason error) (int, error)) error {
+	if err == nil {
+		return nil
+	}
+
+	action, cause := def.ParseHandlerError(err)
+	switch action {
+	case def.ErrorActionContinue:
+		if cc.HandlerErrorHook != nil {
+			cc.HandlerErrorHook(conn, cause)
+		} else {
+			fmt.Printf("handler error: %s\n", cause)
+		}
+		return nil
+	case def.ErrorActionClose:
+		//note: 在 read 协程中等待会导致无法读到对端的关闭，因此在新协程中关闭
+		timeout := cc.CloseFlushTimeout
+		if timeout == 0 {
+			timeout = def.CloseFlushTimeout * time.Second
+		}
+		go closeGracefully(timeout, cause)
+		return nil
+	}
+	return cause
+}
+
+//优雅关闭的原因，对端随之关闭连接时作为 OnClose 的错误
+type closeReason struct {
+	value atomic.Value
+}
+
+type reasonValue struct {
+	err error
+}
+
+func (this *closeReason) set(err error) {
+	this.value.Store(reasonValue{err: err})
+}
+
+func (this *closeReason) get() error {
+	v, _ := this.value.Load().(reasonValue)
+	return v.err
+}
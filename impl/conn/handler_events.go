@@ -0,0 +1,149 @@
+package conn
+
+import (
+	"errors"
+	"fmt"
+	"net"
+	"runtime/debug"
+
+	"github.com/jumperzq86/jumper_conn/interf"
+
+	"github.com/jumperzq86/jumper_conn/def"
+)
+
+//Handler 实现的可选接口，创建连接时检测一次
+//note: 连接关闭时 handler 被置为 nil，这里单独保存，关闭过程中仍然可以调用
+type handlerEvents struct {
+	handler          interf.Handler
+	open             interf.OpenHandler
+	writeError       interf.WriteErrorHandler
+	idle             interf.IdleHandler
+	heartbeatTimeout interf.HeartbeatTimeoutHandler
+	queueDrained     interf.QueueDrainedHandler
+	panicHandler     interf.PanicHandler
+
+	conn        interf.Conn
+	panicPolicy int8
+	panicHook   func(conn interf.Conn, v interface{}, stack []byte)
+}
+
+func newHandlerEvents(conn interf.Conn, cc *def.ConnConfig, handler interf.Handler) *handlerEvents {
+	rc := &handlerEvents{
+		handler:     handler,
+		conn:        conn,
+		panicPolicy: cc.PanicPolicy,
+		panicHook:   cc.PanicHook,
+	}
+	rc.open, _ = handler.(interf.OpenHandler)
+	rc.writeError, _ = handler.(interf.WriteErrorHandler)
+	rc.idle, _ = handler.(interf.IdleHandler)
+	rc.heartbeatTimeout, _ = handler.(interf.HeartbeatTimeoutHandler)
+	rc.queueDrained, _ = handler.(interf.QueueDrainedHandler)
+	rc.panicHandler, _ = handler.(interf.PanicHandler)
+	return rc
+}
+
+//note: 由连接的 handleMessage 统一处理 panic
+func (this *handlerEvents) onMessage(data []byte) error {
+	return this.handler.OnMessage(data)
+}
+
+func (this *handlerEvents) onOpen() (err error) {
+	if this.open == nil {
+		return nil
+	}
+	defer this.catch(&err)
+	this.open.OnOpen()
+	return nil
+}
+
+//连接已经关闭，panic 只上报
+func (this *handlerEvents) onClose(err error) {
+	defer this.catch(nil)
+	this.handler.OnClose(err)
+}
+
+func (this *handlerEvents) onWriteError(data []byte, err error) {
+	if this.writeError == nil {
+		return
+	}
+	defer this.catch(nil)
+	this.writeError.OnWriteError(data, err)
+}
+
+func (this *handlerEvents) onIdle() {
+	if this.idle == nil {
+		return
+	}
+	defer this.catch(nil)
+	this.idle.OnIdle()
+}
+
+func (this *handlerEvents) onHeartbeatTimeout() {
+	if this.heartbeatTimeout == nil {
+		return
+	}
+	defer this.catch(nil)
+	this.heartbeatTimeout.OnHeartbeatTimeout()
+}
+
+func (this *handlerEvents) onQueueDrained() (err error) {
+	if this.queueDrained == nil {
+		return nil
+	}
+	defer this.catch(&err)
+	this.queueDrained.OnQueueDrained()
+	return nil
+}
+
+//调用配置中的 hook，hook 为 nil 时直接返回
+func (this *handlerEvents) onHook(hook func(conn interf.Conn, err error), cause error) (err error) {
+	if hook == nil {
+		return nil
+	}
+	defer this.catch(&err)
+	hook(this.conn, cause)
+	return nil
+}
+
+//执行连接之外的用户代码，如 rpc 的处理函数
+func (this *handlerEvents) run(task func()) (err error) {
+	defer this.catch(&err)
+	task()
+	return nil
+}
+
+////////////////////////////////////////////////////////////// impl
+
+//上报 panic，err 不为 nil 且策略为 PanicClose 时将 panic 转为错误返回
+func (this *handlerEvents) catch(err *error) {
+	v := recover()
+	if v == nil {
+		return
+	}
+	this.report(v, debug.Stack())
+	if err != nil && this.panicPolicy == def.PanicClose {
+		*err = def.New(def.ErrHandlerPanicCode, fmt.Sprintf("handler panic: %v.", v))
+	}
+}
+
+//上报过程中的 panic 直接忽略
+func (this *handlerEvents) report(v interface{}, stack []byte) {
+	defer func() { recover() }()
+
+	if this.panicHandler == nil && this.panicHook == nil {
+		fmt.Printf("handler panic: %v\n%s", v, stack)
+		return
+	}
+	if this.panicHandler != nil {
+		this.panicHandler.OnPanic(v, stack)
+	}
+	if this.panicHook != nil {
+		this.panicHook(this.conn, v, stack)
+	}
+}
+
+func isTimeout(err error) bool {
+	var ne net.Error
+	return errors.As(err, &ne) && ne.Timeout()
+}
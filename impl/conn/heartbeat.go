@@ -0,0 +1,113 @@
+package conn
+
+import (
+	"bytes"
+	"encoding/binary"
+	"sync/atomic"
+	"time"
+
+	"github.com/jumperzq86/jumper_conn/def"
+)
+
+//tcp 应用层心跳，心跳帧在 OnMessage 之前被回复和过滤
+type heartbeat struct {
+	lastRead int64 // 最近一次读到数据的时间
+	pingSent int64 // 未收到回复的 ping 的发送时间
+	rtt      rttStat
+
+	enabled   bool
+	interval  time.Duration
+	miss      int64
+	ping      []byte // 带长度头的心跳帧
+	pong      []byte
+	replyChan chan struct{}
+}
+
+func newHeartbeat(cc *def.ConnConfig) *heartbeat {
+	return &heartbeat{
+		lastRead:  time.Now().UnixNano(),
+		enabled:   cc.HeartbeatInterval > 0 || cc.HeartbeatReply,
+		interval:  cc.HeartbeatInterval,
+		miss:      cc.HeartbeatMiss,
+		ping:      heartbeatFrame(def.HeartbeatPing),
+		pong:      heartbeatFrame(def.HeartbeatPong),
+		replyChan: make(chan struct{}, 1),
+	}
+}
+
+func (this *heartbeat) touch() {
+	atomic.StoreInt64(&this.lastRead, time.Now().UnixNano())
+}
+
+//读到一帧数据后调用，返回 true 表示是心跳帧，不再交给 OnMessage
+//note: 回复由心跳协程写出，避免 read 协程阻塞在写操作上
+func (this *heartbeat) filter(content []byte) bool {
+	if !this.enabled {
+		return false
+	}
+	this.touch()
+
+	if bytes.Equal(content, def.HeartbeatPing) {
+		select {
+		case this.replyChan <- struct{}{}:
+		default:
+		}
+		return true
+	}
+	if !bytes.Equal(content, def.HeartbeatPong) {
+		return false
+	}
+	this.rtt.pong()
+	if sent := atomic.SwapInt64(&this.pingSent, 0); sent != 0 {
+		this.rtt.record(time.Unix(0, sent))
+	}
+	return true
+}
+
+//定时发送心跳并检测超时，paused 为 true 时不检测超时
+func (this *heartbeat) run(closeChan <-chan struct{}, write func(data []byte) error, paused func() bool, timeout func()) {
+	if !this.enabled {
+		return
+	}
+
+	var tick <-chan time.Time
+	if this.interval > 0 {
+		ticker := time.NewTicker(this.interval)
+		defer ticker.Stop()
+		tick = ticker.C
+	}
+
+	for {
+		select {
+		case <-closeChan:
+			return
+		case <-this.replyChan:
+			if write(this.pong) != nil {
+				return
+			}
+		case now := <-tick:
+			if paused() {
+				this.touch()
+				continue
+			}
+			lastRead := time.Unix(0, atomic.LoadInt64(&this.lastRead))
+			if now.Sub(lastRead) >= this.interval*time.Duration(this.miss) {
+				timeout()
+				return
+			}
+			atomic.StoreInt64(&this.pingSent, now.UnixNano())
+			if write(this.ping) != nil {
+				return
+			}
+		}
+	}
+}
+
+////////////////////////////////////////////////////////////// impl
+
+func heartbeatFrame(content []byte) []byte {
+	frame := make([]byte, def.TcpHeadSize+len(content))
+	binary.BigEndian.PutUint32(frame, uint32(len(content)))
+	copy(frame[def.TcpHeadSize:], content)
+	return frame
+}
@@ -0,0 +1,59 @@
+package conn
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/jumperzq86/jumper_conn/def"
+)
+
+//读写两个方向都没有应用数据超过 timeout 时关闭连接，心跳不算在内
+type idleTimeout struct {
+	lastActive int64
+
+	timeout   time.Duration
+	timer     *wheelTimer
+	paused    func() bool
+	onTimeout func()
+}
+
+func newIdleTimeout(cc *def.ConnConfig, paused func() bool, onTimeout func()) *idleTimeout {
+	return &idleTimeout{
+		timeout:   cc.IdleTimeout,
+		paused:    paused,
+		onTimeout: onTimeout,
+	}
+}
+
+func (this *idleTimeout) start() {
+	if this.timeout <= 0 {
+		return
+	}
+	this.touch()
+	this.timer = connWheel.schedule(this.timeout, this.check)
+}
+
+func (this *idleTimeout) stop() {
+	if this.timer != nil {
+		connWheel.cancel(this.timer)
+	}
+}
+
+func (this *idleTimeout) touch() {
+	atomic.StoreInt64(&this.lastActive, time.Now().UnixNano())
+}
+
+//在时间轮协程中执行，暂停读取期间不算空闲
+func (this *idleTimeout) check() time.Duration {
+	if this.paused() {
+		this.touch()
+		return this.timeout
+	}
+
+	idle := time.Since(time.Unix(0, atomic.LoadInt64(&this.lastActive)))
+	if idle < this.timeout {
+		return this.timeout - idle
+	}
+	go this.onTimeout()
+	return 0
+}
@@ -0,0 +1,99 @@
+package conn
+
+import (
+	"sync"
+	"sync/atomic"
+
+	"github.com/jumperzq86/jumper_conn/interf"
+
+	"github.com/jumperzq86/jumper_conn/def"
+)
+
+//连接当前的配置，运行时调整时替换为修改后的副本，读取时不需要加锁
+//note: 创建时传入的 cc 可能被多个连接共享，不能直接修改
+type liveConfig struct {
+	guard sync.Mutex // 串行化调整
+	value atomic.Value
+}
+
+type reconfigurable interface {
+	reconfigure(cu *def.ConnUpdate) error
+}
+
+func newLiveConfig(cc *def.ConnConfig) *liveConfig {
+	rc := &liveConfig{}
+	rc.value.Store(cc)
+	return rc
+}
+
+//运行时调整连接的选项，修改从下一帧开始生效
+func Reconfigure(conn interf.Conn, cu *def.ConnUpdate) error {
+	r, ok := conn.(reconfigurable)
+	if !ok {
+		return def.ErrReconfigureUnsupported
+	}
+	return r.reconfigure(cu)
+}
+
+//对 group 中的所有连接调整，返回调整成功的连接数和第一个错误
+func ReconfigureGroup(group interf.ConnGroup, cu *def.ConnUpdate) (int, error) {
+	var count int
+	var first error
+	group.Range(func(conn interf.Conn) bool {
+		err := Reconfigure(conn, cu)
+		if err == nil {
+			count++
+		} else if first == nil {
+			first = err
+		}
+		return true
+	})
+	return count, first
+}
+
+////////////////////////////////////////////////////////////// impl
+
+//note: 加入后再检查是否已经关闭，避免与 close 并发时已关闭的连接留在组中
+func joinGroup(group interf.ConnGroup, conn interf.Conn) {
+	if group == nil {
+		return
+	}
+	group.Add(conn)
+	if conn.IsClosed() {
+		group.Remove(conn)
+	}
+}
+
+func leaveGroup(group interf.ConnGroup, conn interf.Conn) {
+	if group != nil {
+		group.Remove(conn)
+	}
+}
+
+func (this *liveConfig) get() *def.ConnConfig {
+	return this.value.Load().(*def.ConnConfig)
+}
+
+//检查通过后替换配置并调整限速，检查失败时配置保持不变
+func (this *liveConfig) update(cu *def.ConnUpdate, readLimit *readLimit, writeLimit *writeLimit) error {
+	this.guard.Lock()
+	defer this.guard.Unlock()
+
+	cc := *this.get()
+	err := cu.Apply(&cc)
+	if err != nil {
+		return err
+	}
+	this.value.Store(&cc)
+
+	if cu.WriteRate != nil {
+		writeLimit.setRate(cc.WriteRate, cc.WriteBurst)
+	}
+	if cu.ReadMsgRate != nil {
+		readLimit.msgLimiter.SetLimit(cc.ReadMsgRate, cc.ReadMsgBurst)
+	}
+	if cu.ReadByteRate != nil {
+		readLimit.byteLimiter.SetLimit(cc.ReadByteRate, cc.ReadByteBurst)
+	}
+	return nil
+}
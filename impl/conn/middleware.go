@@ -0,0 +1,66 @@
+package conn
+
+import (
+	"context"
+
+	"github.com/jumperzq86/jumper_conn/interf"
+
+	"github.com/jumperzq86/jumper_conn/def"
+)
+
+//连接上的入方向和出方向 middleware，先执行 ConnOptions 中的，再执行 Use 添加的
+//note: Use 需要在 Run 之前调用，例如在 Handler.Init 中
+type middlewareChain struct {
+	inboundMws  []interf.InboundMiddleware
+	outboundMws []interf.OutboundMiddleware
+	inbound     interf.InboundHandler
+	onMessage   func(data []byte) error
+}
+
+func newMiddlewareChain(cc *def.ConnConfig, onMessage func(data []byte) error) *middlewareChain {
+	rc := &middlewareChain{
+		onMessage: onMessage,
+	}
+	rc.useInbound(cc.InboundMiddlewares...)
+	rc.useOutbound(cc.OutboundMiddlewares...)
+	return rc
+}
+
+func (this *middlewareChain) useInbound(mws ...interf.InboundMiddleware) {
+	this.inboundMws = append(this.inboundMws, mws...)
+
+	handler := interf.InboundHandler(func(conn interf.Conn, data []byte) error {
+		return this.onMessage(data)
+	})
+	for i := len(this.inboundMws) - 1; i >= 0; i-- {
+		handler = this.inboundMws[i](handler)
+	}
+	this.inbound = handler
+}
+
+func (this *middlewareChain) useOutbound(mws ...interf.OutboundMiddleware) {
+	this.outboundMws = append(this.outboundMws, mws...)
+}
+
+func (this *middlewareChain) handleMessage(conn interf.Conn, data []byte) error {
+	return this.inbound(conn, data)
+}
+
+//经过出方向链后调用 do 写入，返回 false 表示数据被 middleware 丢弃
+//note: 链的末端在每次写入时创建并直接持有 do，middleware 传给 next 的 ctx 不影响写入
+func (this *middlewareChain) write(ctx context.Context, conn interf.Conn, data []byte, do func(ctx context.Context, data []byte) error) (bool, error) {
+	if len(this.outboundMws) == 0 {
+		return true, do(ctx, data)
+	}
+
+	called := false
+	handler := interf.OutboundHandler(func(ctx context.Context, conn interf.Conn, data []byte) error {
+		called = true
+		return do(ctx, data)
+	})
+	for i := len(this.outboundMws) - 1; i >= 0; i-- {
+		handler = this.outboundMws[i](handler)
+	}
+	err := handler(ctx, conn, data)
+	return called, err
+}
@@ -0,0 +1,58 @@
+package conn
+
+import (
+	"context"
+
+	"github.com/jumperzq86/jumper_conn/impl/limit"
+	"github.com/jumperzq86/jumper_conn/interf"
+
+	"github.com/jumperzq86/jumper_conn/def"
+)
+
+//入方向限制，按消息数和字节数分别限速
+type readLimit struct {
+	msgLimiter  interf.Limiter
+	byteLimiter interf.Limiter
+	policy      int8
+	hook        func(conn interf.Conn, err error)
+}
+
+func newReadLimit(cc *def.ConnConfig) *readLimit {
+	return &readLimit{
+		msgLimiter:  limit.CreatetokenBucket(cc.ReadMsgRate, cc.ReadMsgBurst),
+		byteLimiter: limit.CreatetokenBucket(cc.ReadByteRate, cc.ReadByteBurst),
+		policy:      cc.ReadLimitPolicy,
+		hook:        cc.ReadLimitHook,
+	}
+}
+
+//每读到一条消息调用一次，返回 false 表示丢弃该消息，返回错误表示需要关闭连接
+//ReadLimitDelay 时在此阻塞，read 协程不再读取，对端发送速度由 tcp 流控降低
+//hook 中的 panic 按 PanicPolicy 处理
+func (this *readLimit) check(ctx context.Context, events *handlerEvents, size int) (bool, error) {
+	msgOk := this.msgLimiter.AllowN(1)
+	byteOk := this.byteLimiter.AllowN(size)
+	if msgOk && byteOk {
+		return true, nil
+	}
+
+	err := events.onHook(this.hook, def.ErrReadLimitExceeded)
+	if err != nil {
+		return false, err
+	}
+
+	switch this.policy {
+	case def.ReadLimitDrop:
+		return false, nil
+	case def.ReadLimitClose:
+		return false, def.ErrReadLimitExceeded
+	}
+
+	if !msgOk && this.msgLimiter.WaitN(ctx, 1) != nil {
+		return false, def.ErrConnClosed
+	}
+	if !byteOk && this.byteLimiter.WaitN(ctx, size) != nil {
+		return false, def.ErrConnClosed
+	}
+	return true, nil
+}
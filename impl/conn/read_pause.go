@@ -0,0 +1,73 @@
+package conn
+
+import (
+	"sync"
+)
+
+//由应用控制的暂停读取，暂停期间不读取数据，对端发送速度由 tcp 流控降低
+//note: 设置和清除读超时都在锁内进行，避免暂停之后读超时又被设置
+type readPause struct {
+	guard      sync.Mutex
+	paused     bool
+	resumeChan chan struct{} // 恢复读取时 close
+
+	setDeadline   func()
+	clearDeadline func()
+}
+
+func newReadPause(setDeadline func(), clearDeadline func()) *readPause {
+	return &readPause{
+		setDeadline:   setDeadline,
+		clearDeadline: clearDeadline,
+	}
+}
+
+//正在进行的读取不会被打断，清除其读超时，读到的消息处理完后再暂停
+func (this *readPause) pause() {
+	this.guard.Lock()
+	defer this.guard.Unlock()
+	if this.paused {
+		return
+	}
+	this.paused = true
+	this.resumeChan = make(chan struct{})
+	this.clearDeadline()
+}
+
+func (this *readPause) resume() {
+	this.guard.Lock()
+	defer this.guard.Unlock()
+	if !this.paused {
+		return
+	}
+	this.paused = false
+	//暂停时正在进行的读取重新计时
+	this.setDeadline()
+	close(this.resumeChan)
+}
+
+func (this *readPause) isPaused() bool {
+	this.guard.Lock()
+	defer this.guard.Unlock()
+	return this.paused
+}
+
+//每次读取之前调用，暂停时阻塞，未暂停时设置读超时，连接关闭时返回 false
+func (this *readPause) wait(closeChan <-chan struct{}) bool {
+	for {
+		this.guard.Lock()
+		if !this.paused {
+			this.setDeadline()
+			this.guard.Unlock()
+			return true
+		}
+		resumeChan := this.resumeChan
+		this.guard.Unlock()
+
+		select {
+		case <-resumeChan:
+		case <-closeChan:
+			return false
+		}
+	}
+}
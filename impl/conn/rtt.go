@@ -0,0 +1,39 @@
+package conn
+
+import (
+	"sync/atomic"
+	"time"
+)
+
+//ping/pong 往返时间和最近一次收到 pong 的时间
+type rttStat struct {
+	rtt      int64 // 往返时间的平滑值
+	lastPong int64
+}
+
+//每次收到 pong 时调用，不论其中是否带有发送时间
+func (this *rttStat) pong() {
+	atomic.StoreInt64(&this.lastPong, time.Now().UnixNano())
+}
+
+//收到 sent 时发出的 ping 对应的 pong，只更新往返时间
+func (this *rttStat) record(sent time.Time) {
+	cost := int64(time.Since(sent))
+	if cost < 0 {
+		return
+	}
+	smoothStore(&this.rtt, cost)
+}
+
+func (this *rttStat) getRTT() time.Duration {
+	return time.Duration(atomic.LoadInt64(&this.rtt))
+}
+
+//还没有收到过 pong 时返回零值
+func (this *rttStat) getLastPong() time.Time {
+	lastPong := atomic.LoadInt64(&this.lastPong)
+	if lastPong == 0 {
+		return time.Time{}
+	}
+	return time.Unix(0, lastPong)
+}
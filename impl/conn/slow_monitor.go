@@ -0,0 +1,116 @@
+package conn
+
+import (
+	"sync/atomic"
+	"time"
+
+	"github.com/jumperzq86/jumper_conn/interf"
+
+	"github.com/jumperzq86/jumper_conn/def"
+)
+
+const slowCheckPeriod = time.Second
+
+//记录写耗时和发送队列占用，检测读取过慢的对端
+type slowMonitor struct {
+	writeStart int64 // 当前写操作的开始时间，0 表示没有在写
+	latency    int64 // 写耗时的平滑值
+
+	queue         *writeQueue
+	queuePercent  int64
+	queueDuration time.Duration
+	writeTimeout  time.Duration
+	evict         bool
+	hook          func(conn interf.Conn, err error)
+
+	fullSince time.Time
+	reported  int64 // 已经上报过的写操作开始时间，同一次写只上报一次
+}
+
+func newSlowMonitor(cc *def.ConnConfig, queue *writeQueue) *slowMonitor {
+	return &slowMonitor{
+		queue:         queue,
+		queuePercent:  cc.SlowQueuePercent,
+		queueDuration: cc.SlowQueueDuration,
+		writeTimeout:  cc.SlowWriteTimeout,
+		evict:         cc.SlowConsumerEvict,
+		hook:          cc.SlowConsumerHook,
+	}
+}
+
+func (this *slowMonitor) beginWrite() {
+	atomic.StoreInt64(&this.writeStart, time.Now().UnixNano())
+}
+
+func (this *slowMonitor) endWrite() {
+	start := atomic.SwapInt64(&this.writeStart, 0)
+	if start == 0 {
+		return
+	}
+	smoothStore(&this.latency, time.Now().UnixNano()-start)
+}
+
+func (this *slowMonitor) getLatency() time.Duration {
+	return time.Duration(atomic.LoadInt64(&this.latency))
+}
+
+//定时检测，判定为慢消费者时调用 hook，evict 时以对应的错误关闭连接
+//hook 中的 panic 按 PanicPolicy 处理，PanicClose 时关闭连接
+func (this *slowMonitor) run(events *handlerEvents, closeChan <-chan struct{}, closeFunc func(err error)) {
+	if this.queueDuration <= 0 && this.writeTimeout <= 0 {
+		return
+	}
+
+	ticker := time.NewTicker(slowCheckPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-closeChan:
+			return
+		case now := <-ticker.C:
+			err := this.check(now)
+			if err == nil {
+				continue
+			}
+			hookErr := events.onHook(this.hook, err)
+			if hookErr != nil {
+				closeFunc(hookErr)
+				return
+			}
+			if this.evict {
+				closeFunc(err)
+				return
+			}
+		}
+	}
+}
+
+////////////////////////////////////////////////////////////// impl
+
+func (this *slowMonitor) check(now time.Time) error {
+	if this.writeTimeout > 0 {
+		start := atomic.LoadInt64(&this.writeStart)
+		if start != 0 && start != this.reported && now.Sub(time.Unix(0, start)) > this.writeTimeout {
+			this.reported = start
+			return def.ErrSlowConsumerWrite
+		}
+	}
+
+	if this.queueDuration > 0 {
+		if this.queue.fillPercent() < this.queuePercent {
+			this.fullSince = time.Time{}
+			return nil
+		}
+		if this.fullSince.IsZero() {
+			this.fullSince = now
+			return nil
+		}
+		if now.Sub(this.fullSince) >= this.queueDuration {
+			this.fullSince = now
+			return def.ErrSlowConsumerQueue
+		}
+	}
+
+	return nil
+}
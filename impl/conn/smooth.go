@@ -0,0 +1,19 @@
+package conn
+
+import (
+	"sync/atomic"
+)
+
+//以平滑系数 1/8 将 sample 计入 addr 中的平滑值，addr 为 0 时直接使用 sample
+func smoothStore(addr *int64, sample int64) {
+	for {
+		old := atomic.LoadInt64(addr)
+		value := sample
+		if old != 0 {
+			value = old + (sample-old)/8
+		}
+		if atomic.CompareAndSwapInt64(addr, old, value) {
+			return
+		}
+	}
+}
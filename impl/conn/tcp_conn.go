@@ -1,7 +1,7 @@
 package conn
 
 import (
-	"encoding/binary"
+	"context"
 	"fmt"
 	"io"
 	"net"
@@ -9,39 +9,89 @@ import (
 	"sync/atomic"
 	"time"
 
+	"github.com/jumperzq86/jumper_conn/impl/codec"
 	"github.com/jumperzq86/jumper_conn/interf"
+	"github.com/jumperzq86/jumper_conn/util"
 
 	"github.com/jumperzq86/jumper_conn/def"
 )
 
 type tcpConn struct {
-	closed      int32
-	writeBuffer chan []byte
-	closeChan   chan struct{}
+	closed     int32
+	closing    int32
+	writeQueue *writeQueue
+	closeChan  chan struct{}
+	closeCtx   context.Context // 连接关闭时取消
+	cancel     context.CancelFunc
 
 	ctx     map[string]interface{}
 	conn    net.Conn
 	handler interf.Handler
-	co      *def.ConnOptions
-
-	dataGuard  sync.Mutex // 保证在并发情况下，一个命令接一个命令完整地发送出去，而不是多个命令的数据混淆发送
+	cc      *def.ConnConfig
+	live    *liveConfig // 运行时可以调整的配置
+	decoder interf.Decoder
+
+	dataGuard  writeGuard // 保证在并发情况下，一个命令接一个命令完整地发送出去，而不是多个命令的数据混淆发送
+	writeLimit *writeLimit
+	readLimit  *readLimit
+	slow       *slowMonitor
+	dispatcher *dispatcher // 使用协程池时不为 nil
+	events     *handlerEvents
+	middleware *middlewareChain
+	pause      *readPause
+	reason     closeReason
+	idle       *idleTimeout
+	heartbeat  *heartbeat
 }
 
+//兼容旧的配置方式，时间字段为秒数
 func CreatetcpConn(conn net.Conn, co *def.ConnOptions, handler interf.Handler) (interf.Conn, error) {
-
 	err := co.CheckValid()
 	if err != nil {
 		return nil, err
 	}
+	return CreatetcpConnWithConfig(conn, co.Config(), handler)
+}
+
+func CreatetcpConnWithConfig(conn net.Conn, cc *def.ConnConfig, handler interf.Handler) (interf.Conn, error) {
+
+	err := cc.CheckValid()
+	if err != nil {
+		return nil, err
+	}
+	err = util.ApplySocketOptions(conn, cc)
+	if err != nil {
+		return nil, err
+	}
 
 	rc := &tcpConn{
-		conn:        conn,
-		closed:      0,
-		writeBuffer: make(chan []byte, co.AsyncWriteSize),
-		closeChan:   make(chan struct{}),
-		co:          co,
-		ctx:         make(map[string]interface{}),
-		handler:     handler,
+		conn:       conn,
+		closed:     0,
+		writeQueue: newWriteQueue(cc),
+		closeChan:  make(chan struct{}),
+		cc:         cc,
+		live:       newLiveConfig(cc),
+		ctx:        make(map[string]interface{}),
+		handler:    handler,
+		decoder:    cc.Decoder,
+		dataGuard:  newWriteGuard(),
+		writeLimit: newWriteLimit(cc),
+		readLimit:  newReadLimit(cc),
+	}
+	rc.slow = newSlowMonitor(cc, rc.writeQueue)
+	rc.heartbeat = newHeartbeat(cc)
+	rc.events = newHandlerEvents(rc, cc, handler)
+	rc.middleware = newMiddlewareChain(cc, rc.events.onMessage)
+	rc.pause = newReadPause(func() {
+		rc.setReadDeadline(rc.live.get().ReadTimeout)
+	}, func() {
+		rc.conn.SetReadDeadline(time.Time{})
+	})
+	rc.idle = newIdleTimeout(cc, rc.pause.isPaused, rc.onIdleTimeout)
+	rc.dispatcher = newDispatcher(cc, rc.closeChan, rc.handleMessage, rc.close)
+	rc.closeCtx, rc.cancel = context.WithCancel(context.Background())
+	if rc.decoder == nil {
+		rc.decoder = codec.CreatedefaultDecoder(tcpMaxMsgSize(cc))
 	}
 
 	return rc, nil
@@ -66,58 +116,208 @@ func (this *tcpConn) Close() {
 	this.close(nil)
 }
 
+//停止接收新的写入，等待队列中的数据写完后半关闭连接，等待对端关闭或超时后关闭连接
+//超时时返回未能发送的消息数
+func (this *tcpConn) CloseGracefully(timeout time.Duration) (int, error) {
+	return this.closeGracefully(timeout, nil)
+}
+
+//reason 不为 nil 时作为 OnClose 的错误
+func (this *tcpConn) closeGracefully(timeout time.Duration, reason error) (int, error) {
+	if this.IsClosed() {
+		return 0, def.ErrConnClosed
+	}
+	if !atomic.CompareAndSwapInt32(&this.closing, 0, 1) {
+		return 0, def.ErrConnClosing
+	}
+	this.reason.set(reason)
+	this.writeQueue.stop()
+
+	//等待写锁和对端关闭都使用同一个截止时间
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if !this.writeQueue.waitEmpty(ctx.Done(), this.closeChan) {
+		dropped := this.writeQueue.size()
+		if this.IsClosed() {
+			return dropped, def.ErrConnClosed
+		}
+		this.close(def.ErrCloseTimeout)
+		return dropped, def.ErrCloseTimeout
+	}
+
+	//note: 半关闭后对端读到 EOF 关闭连接，read 协程随之结束
+	if c, ok := this.conn.(interface{ CloseWrite() error }); ok {
+		//Write 阻塞时一直拿不到写锁，超时后直接关闭
+		if this.dataGuard.lock(ctx) != nil {
+			this.close(def.ErrCloseTimeout)
+			return 0, def.ErrCloseTimeout
+		}
+		c.CloseWrite()
+		this.dataGuard.unlock()
+
+		select {
+		case <-this.closeChan:
+		case <-ctx.Done():
+		}
+	}
+
+	this.close(reason)
+	return 0, nil
+}
+
 func (this *tcpConn) IsClosed() bool {
 	return atomic.LoadInt32(&this.closed) == 1
 }
 
 func (this *tcpConn) Write(data []byte) error {
+	return this.WriteContext(context.Background(), data)
+}
+
+func (this *tcpConn) WriteContext(ctx context.Context, data []byte) error {
 	closed := this.IsClosed()
 	if closed {
 		return def.ErrConnClosed
 	}
+	if this.isClosing() {
+		return def.ErrConnClosing
+	}
 
-	return this.writeData(data)
+	_, err := this.middleware.write(ctx, this, data, this.writeData)
+	if err == nil {
+		this.idle.touch()
+	}
+	return err
 }
 
+//ctx 在开始写之前检查，开始写之后其截止时间和 WriteTimeout 中较早的一个作为写超时
+//note: 写出部分数据后失败时对端的分帧已经错乱，此时关闭连接
+func (this *tcpConn) writeData(ctx context.Context, data []byte) error {
+	err := this.dataGuard.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer this.dataGuard.unlock()
+
+	err = ctx.Err()
+	if err != nil {
+		return err
+	}
+
+	defer this.conn.SetWriteDeadline(time.Time{})
+
+	sent := 0
+	err = this.writeLimit.write(ctx, this.closeCtx, data, func(chunk []byte) error {
+		this.setWriteDeadline(ctx)
+		this.slow.beginWrite()
+		defer this.slow.endWrite()
+		n, err := this.writeFull(chunk)
+		sent += n
+		return err
+	})
+	if err != nil && sent > 0 {
+		this.close(err)
+	}
+	return err
+}
 
-func (this *tcpConn) writeData(data []byte) error{
+//返回写出的字节数
+func (this *tcpConn) writeFull(data []byte) (int, error) {
 	length := len(data)
 	written := 0
 	var err error
 	var l int
 
-	this.dataGuard.Lock()
-	defer this.dataGuard.Unlock()
-
-
-	this.setWriteDeadline(this.co.WriteTimeout)
-	defer this.setWriteDeadline(0)
-
 	for {
 		l, err = this.conn.Write(data[written:])
+		written += l
 		if err != nil {
 			break
 		}
-		written += l
 		if written == length {
 			break
 		}
 	}
 
-	return err
+	return written, err
 }
 
+func (this *tcpConn) AsyncWrite(data []byte) (err error) {
+	return this.AsyncWriteContext(context.Background(), data)
+}
 
+func (this *tcpConn) AsyncWriteContext(ctx context.Context, data []byte) (err error) {
+	return this.AsyncWritePriority(ctx, def.PriorityNormal, data)
+}
 
-func (this *tcpConn) AsyncWrite(data []byte) (err error) {
+func (this *tcpConn) AsyncWritePriority(ctx context.Context, priority int8, data []byte) (err error) {
+	_, err = this.middleware.write(ctx, this, data, func(ctx context.Context, data []byte) error {
+		return this.enqueue(ctx, priority, data, nil)
+	})
+	return err
+}
 
-	closed := this.IsClosed()
-	if closed {
-		return def.ErrConnClosed
+func (this *tcpConn) AsyncWriteFuture(ctx context.Context, priority int8, data []byte) interf.WriteFuture {
+	future := newWriteFuture()
+	written, err := this.middleware.write(ctx, this, data, func(ctx context.Context, data []byte) error {
+		return this.enqueue(ctx, priority, data, future)
+	})
+	if err == nil && !written {
+		err = def.ErrWriteDroppedByMiddleware
+	}
+	if err != nil || !written {
+		future.resolve(err)
 	}
+	return future
+}
 
-	this.writeBuffer <- data
-	return nil
+//根据应用层心跳计算，未开启心跳时为 0
+func (this *tcpConn) RTT() time.Duration {
+	return this.heartbeat.rtt.getRTT()
+}
+
+func (this *tcpConn) LastPong() time.Time {
+	return this.heartbeat.rtt.getLastPong()
+}
+
+func (this *tcpConn) PauseRead() {
+	this.pause.pause()
+}
+
+func (this *tcpConn) ResumeRead() {
+	this.pause.resume()
+}
+
+func (this *tcpConn) IsReadPaused() bool {
+	return this.pause.isPaused()
+}
+
+func (this *tcpConn) UseInbound(mws ...interf.InboundMiddleware) {
+	this.middleware.useInbound(mws...)
+}
+
+func (this *tcpConn) UseOutbound(mws ...interf.OutboundMiddleware) {
+	this.middleware.useOutbound(mws...)
+}
+
+func (this *tcpConn) SetWriteRate(rate, burst int64) {
+	this.writeLimit.setRate(rate, burst)
+}
+
+func (this *tcpConn) QueueLen() int {
+	return this.writeQueue.size()
+}
+
+func (this *tcpConn) QueueBytes() int64 {
+	return this.writeQueue.byteSize()
+}
+
+func (this *tcpConn) WriteLatency() time.Duration {
+	return this.slow.getLatency()
+}
+
+func (this *tcpConn) Dropped() uint64 {
+	return this.writeQueue.getDropped()
 }
 
 func (this *tcpConn) Set(key string, value interface{}) {
@@ -135,17 +335,40 @@ func (this *tcpConn) Del(key string) {
 	delete(this.ctx, key)
 }
 
+//实现 interf.TaskRunner，PanicClose 时关闭连接
+func (this *tcpConn) Go(task func()) {
+	go func() {
+		err := this.events.run(task)
+		if err != nil {
+			this.close(err)
+		}
+	}()
+}
+
 ////////////////////////////////////////////////////////////// impl
 
-func (this *tcpConn) setWriteDeadline(timeout int64) {
-	if this.co.WriteTimeout > 0 {
-		this.conn.SetWriteDeadline(time.Now().Add(time.Duration(timeout) * time.Second))
-	}
+//入方向 middleware 和 OnMessage 中的 panic 按 PanicPolicy 处理
+func (this *tcpConn) handleMessage(data []byte) (err error) {
+	defer this.events.catch(&err)
+	err = this.middleware.handleMessage(this, data)
+	return handleError(this, this.cc, err, this.closeGracefully)
 }
 
-func (this *tcpConn) setReadDeadline(timeout int64) {
-	if this.co.ReadTimeout > 0 {
-		this.conn.SetReadDeadline(time.Now().Add(time.Duration(timeout) * time.Second))
+func (this *tcpConn) reconfigure(cu *def.ConnUpdate) error {
+	return this.live.update(cu, this.readLimit, this.writeLimit)
+}
+
+func (this *tcpConn) isClosing() bool {
+	return atomic.LoadInt32(&this.closing) == 1
+}
+
+func (this *tcpConn) setWriteDeadline(ctx context.Context) {
+	this.conn.SetWriteDeadline(getWriteDeadline(ctx, this.live.get().WriteTimeout))
+}
+
+func (this *tcpConn) setReadDeadline(timeout time.Duration) {
+	if this.live.get().ReadTimeout > 0 {
+		this.conn.SetReadDeadline(time.Now().Add(timeout))
 	}
 }
 
@@ -156,15 +379,37 @@ func (this *tcpConn) close(err error) {
 	}
 
 	close(this.closeChan)
+	this.cancel()
+	this.idle.stop()
+	leaveGroup(this.cc.Group, this)
+	this.writeQueue.clear(def.ErrWriteDroppedOnClose)
 
 	this.conn.Close()
 
-	this.handler.OnClose(err)
+	this.events.onClose(err)
 
 	this.ctx = nil
 	this.handler = nil
 }
 
+//心跳帧不经过出方向 middleware 和发送队列
+func (this *tcpConn) writeHeartbeat(data []byte) error {
+	return this.writeData(this.closeCtx, data)
+}
+
+func (this *tcpConn) onHeartbeatTimeout() {
+	this.events.onHeartbeatTimeout()
+	this.close(def.ErrHeartbeatTimeout)
+}
+
+func (this *tcpConn) onIdleTimeout() {
+	if this.IsClosed() {
+		return
+	}
+	this.events.onIdle()
+	this.close(def.ErrIdleTimeout)
+}
+
 func (this *tcpConn) asyncWrite(wg *sync.WaitGroup) error {
 
 	wg.Done()
@@ -176,16 +421,24 @@ writeLoop:
 		case <-this.closeChan:
 			err = def.ErrConnClosed
 			break writeLoop
-		case data, ok := <-this.writeBuffer:
+		default:
+			item, ok := this.writeQueue.pop(this.closeChan)
 			if !ok {
 				err = def.ErrConnClosed
 				break writeLoop
 			}
 
-			err = this.writeData(data)
+			err = this.writeItem(item)
+			drained := this.writeQueue.done()
 			if err != nil {
 				break writeLoop
 			}
+			if drained {
+				err = this.events.onQueueDrained()
+				if err != nil {
+					break writeLoop
+				}
+			}
 		}
 	}
 
@@ -193,9 +446,44 @@ writeLoop:
 	return err
 }
 
+func (this *tcpConn) enqueue(ctx context.Context, priority int8, data []byte, future *writeFuture) (err error) {
+
+	closed := this.IsClosed()
+	if closed {
+		return def.ErrConnClosed
+	}
+
+	err = this.writeQueue.push(ctx, priority, data, future, this.closeChan)
+	if err == def.ErrSlowConnClosed {
+		this.close(err)
+	}
+	return err
+}
+
+func (this *tcpConn) writeItem(item *writeItem) error {
+	//还未写出就已经被取消的数据直接跳过
+	err := item.ctx.Err()
+	if err == nil {
+		err = this.writeData(item.ctx, item.data)
+	}
+	if err == nil {
+		this.idle.touch()
+	}
+	item.finish(err)
+
+	if err != nil && err == item.ctx.Err() {
+		return nil
+	}
+	if err != nil {
+		this.events.onWriteError(item.data, err)
+	}
+	return err
+}
+
 func (this *tcpConn) read(wg *sync.WaitGroup) (err error) {
 
 	wg.Done()
+	maxMsgSize := tcpMaxMsgSize(this.live.get())
 readLoop:
 	for {
 		select {
@@ -204,17 +492,19 @@ readLoop:
 			break readLoop
 		default:
 
-			this.setReadDeadline(this.co.ReadTimeout)
-
-			length := make([]byte, def.TcpHeadSize)
-			_, err = io.ReadFull(this.conn, length)
-			if err != nil {
+			if !this.pause.wait(this.closeChan) {
+				err = def.ErrConnClosed
 				break readLoop
 			}
-			left := binary.BigEndian.Uint32(length)
 
-			content := make([]byte, left)
-			_, err = io.ReadFull(this.conn, content)
+			//MaxMsgSize 在运行时调整后重新创建默认的分帧方式，使用自定义 Decoder 时不能调整
+			if size := tcpMaxMsgSize(this.live.get()); size != maxMsgSize {
+				maxMsgSize = size
+				this.decoder = codec.CreatedefaultDecoder(size)
+			}
+
+			var content []byte
+			content, err = this.decoder.Decode(this.conn)
 			if err != nil {
 				break readLoop
 			}
@@ -222,13 +512,41 @@ readLoop:
 			//process msg 可能会花较长时间，导致读超时断开
 			this.setReadDeadline(0)
 
-			err = this.handler.OnMessage(content)
+			if this.heartbeat.filter(content) {
+				continue
+			}
+
+			this.idle.touch()
+
+			var ok bool
+			ok, err = this.readLimit.check(this.closeCtx, this.events, len(content))
+			if err != nil {
+				break readLoop
+			}
+			if !ok {
+				continue
+			}
+
+			if this.dispatcher != nil {
+				err = this.dispatcher.dispatch(content)
+			} else {
+				err = this.handleMessage(content)
+			}
 			if err != nil {
 				break readLoop
 			}
 		}
 	}
 
+	if isTimeout(err) {
+		this.events.onIdle()
+	}
+
+	//优雅关闭时对端关闭连接属于正常结束
+	if err == io.EOF && this.isClosing() {
+		err = this.reason.get()
+	}
+
 	this.close(err)
 	return
 }
@@ -237,20 +555,43 @@ func (this *tcpConn) run() {
 	if this.IsClosed() {
 		return
 	}
+	joinGroup(this.cc.Group, this)
+	this.idle.start()
+	go this.slow.run(this.events, this.closeChan, this.close)
+	go this.heartbeat.run(this.closeChan, this.writeHeartbeat, this.pause.isPaused, this.onHeartbeatTimeout)
+
 	wg := &sync.WaitGroup{}
-	wg.Add(2)
+	wg.Add(1)
 	go func() {
-		err := this.read(wg)
+		err := this.asyncWrite(wg)
 		if err != nil {
-			fmt.Printf("stop read , err: %s\n", err)
+			fmt.Printf("stop async write , err: %s\n", err)
 		}
 	}()
+
+	wg.Wait()
+
+	//note: 在开始读取之前调用，保证 OnMessage 不会早于 OnOpen
+	err := this.events.onOpen()
+	if err != nil {
+		this.close(err)
+		return
+	}
+
+	wg.Add(1)
 	go func() {
-		err := this.asyncWrite(wg)
+		err := this.read(wg)
 		if err != nil {
-			fmt.Printf("stop async write , err: %s\n", err)
+			fmt.Printf("stop read , err: %s\n", err)
 		}
 	}()
-
 	wg.Wait()
 }
+
+//默认分帧方式的消息大小上限，未开启 LimitTcpMsgSize 时与旧版本一致不按 MaxMsgSize 限制
+func tcpMaxMsgSize(cc *def.ConnConfig) int64 {
+	if !cc.LimitTcpMsgSize {
+		return 0
+	}
+	return cc.MaxMsgSize
+}
@@ -0,0 +1,105 @@
+package conn
+
+import (
+	"sync"
+	"time"
+)
+
+const (
+	wheelTick  = time.Second
+	wheelSlots = 512
+)
+
+//所有连接共享的时间轮，避免每个连接各自创建定时器
+var connWheel = newTimerWheel(wheelTick, wheelSlots)
+
+//fn 在时间轮协程中执行，不能阻塞，返回值大于 0 时在该时间之后再次执行
+type wheelTimer struct {
+	slot    int
+	rounds  int
+	stopped bool
+	fn      func() time.Duration
+}
+
+type timerWheel struct {
+	guard sync.Mutex
+	once  sync.Once
+	tick  time.Duration
+	slots []map[*wheelTimer]struct{}
+	pos   int
+}
+
+func newTimerWheel(tick time.Duration, slotNum int) *timerWheel {
+	slots := make([]map[*wheelTimer]struct{}, slotNum)
+	for i := range slots {
+		slots[i] = make(map[*wheelTimer]struct{})
+	}
+	return &timerWheel{
+		tick:  tick,
+		slots: slots,
+	}
+}
+
+func (this *timerWheel) schedule(delay time.Duration, fn func() time.Duration) *wheelTimer {
+	this.once.Do(func() {
+		go this.run()
+	})
+
+	timer := &wheelTimer{fn: fn}
+	this.guard.Lock()
+	this.add(timer, delay)
+	this.guard.Unlock()
+	return timer
+}
+
+func (this *timerWheel) cancel(timer *wheelTimer) {
+	this.guard.Lock()
+	timer.stopped = true
+	delete(this.slots[timer.slot], timer)
+	this.guard.Unlock()
+}
+
+////////////////////////////////////////////////////////////// impl
+
+//调用方持有锁
+func (this *timerWheel) add(timer *wheelTimer, delay time.Duration) {
+	ticks := int((delay + this.tick - 1) / this.tick)
+	if ticks < 1 {
+		ticks = 1
+	}
+	timer.slot = (this.pos + ticks) % len(this.slots)
+	timer.rounds = (ticks - 1) / len(this.slots)
+	this.slots[timer.slot][timer] = struct{}{}
+}
+
+func (this *timerWheel) run() {
+	ticker := time.NewTicker(this.tick)
+	defer ticker.Stop()
+
+	for range ticker.C {
+		this.guard.Lock()
+		this.pos = (this.pos + 1) % len(this.slots)
+		var expired []*wheelTimer
+		for timer := range this.slots[this.pos] {
+			if timer.rounds > 0 {
+				timer.rounds--
+				continue
+			}
+			delete(this.slots[this.pos], timer)
+			expired = append(expired, timer)
+		}
+		this.guard.Unlock()
+
+		for _, timer := range expired {
+			delay := timer.fn()
+			if delay > 0 {
+				this.guard.Lock()
+				//note: 执行 fn 期间可能已经被取消
+				if !timer.stopped {
+					this.add(timer, delay)
+				}
+				this.guard.Unlock()
+			}
+		}
+	}
+}
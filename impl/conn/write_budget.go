@@ -0,0 +1,76 @@
+package conn
+
+import (
+	"sync"
+
+	"github.com/jumperzq86/jumper_conn/def"
+)
+
+//所有连接共享的发送队列内存上限，数据从入队开始占用，写出或丢弃后释放
+type memoryBudget struct {
+	guard     sync.Mutex
+	limit     int64
+	used      int64
+	waiting   bool
+	spaceChan chan struct{} // 有内存释放时 close，唤醒阻塞中的写入方
+}
+
+var writeBudget = &memoryBudget{
+	spaceChan: make(chan struct{}),
+}
+
+//设置所有连接发送队列中未发送数据的总字节数上限，0 表示不限制
+//note: 超过上限的单条数据以 def.ErrWriteExceedsBudget 拒绝
+func SetWriteMemoryBudget(limit int64) {
+	writeBudget.guard.Lock()
+	writeBudget.limit = limit
+	writeBudget.wakeWaiting()
+	writeBudget.guard.Unlock()
+}
+
+//所有连接发送队列中未发送数据的总字节数，包括正在写出的数据
+func GetWriteMemoryUsed() int64 {
+	writeBudget.guard.Lock()
+	defer writeBudget.guard.Unlock()
+	return writeBudget.used
+}
+
+//占用成功时返回 nil, nil
+//内存不足时在同一临界区内登记为等待方，返回用于等待内存释放的 chan
+//单条数据超过上限时返回 def.ErrWriteExceedsBudget
+func (this *memoryBudget) acquire(n int64) (<-chan struct{}, error) {
+	this.guard.Lock()
+	defer this.guard.Unlock()
+
+	if this.limit > 0 {
+		if n > this.limit {
+			return nil, def.ErrWriteExceedsBudget
+		}
+		if this.used+n > this.limit {
+			this.waiting = true
+			return this.spaceChan, nil
+		}
+	}
+	this.used += n
+	return nil, nil
+}
+
+func (this *memoryBudget) release(n int64) {
+	if n == 0 {
+		return
+	}
+	this.guard.Lock()
+	this.used -= n
+	this.wakeWaiting()
+	this.guard.Unlock()
+}
+
+////////////////////////////////////////////////////////////// impl
+
+func (this *memoryBudget) wakeWaiting() {
+	if this.waiting {
+		this.waiting = false
+		close(this.spaceChan)
+		this.spaceChan = make(chan struct{})
+	}
+}
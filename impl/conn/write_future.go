@@ -0,0 +1,50 @@
+package conn
+
+import (
+	"context"
+	"sync"
+)
+
+type writeFuture struct {
+	once sync.Once
+	done chan struct{}
+	err  error
+}
+
+func newWriteFuture() *writeFuture {
+	return &writeFuture{
+		done: make(chan struct{}),
+	}
+}
+
+func (this *writeFuture) Done() <-chan struct{} {
+	return this.done
+}
+
+func (this *writeFuture) Err() error {
+	select {
+	case <-this.done:
+		return this.err
+	default:
+		return nil
+	}
+}
+
+func (this *writeFuture) Wait(ctx context.Context) error {
+	select {
+	case <-this.done:
+		return this.err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+////////////////////////////////////////////////////////////// impl
+
+//只有第一次设置的结果有效
+func (this *writeFuture) resolve(err error) {
+	this.once.Do(func() {
+		this.err = err
+		close(this.done)
+	})
+}
@@ -0,0 +1,38 @@
+package conn
+
+import (
+	"context"
+	"time"
+)
+
+//可被 ctx 取消的互斥锁，保证在并发情况下一次只有一个协程写连接
+type writeGuard chan struct{}
+
+func newWriteGuard() writeGuard {
+	return make(writeGuard, 1)
+}
+
+func (this writeGuard) lock(ctx context.Context) error {
+	select {
+	case this <- struct{}{}:
+		return nil
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (this writeGuard) unlock() {
+	<-this
+}
+
+//取 WriteTimeout 和 ctx 截止时间中较早的一个，都没有时返回零值表示不超时
+func getWriteDeadline(ctx context.Context, timeout time.Duration) time.Time {
+	var deadline time.Time
+	if timeout > 0 {
+		deadline = time.Now().Add(timeout)
+	}
+	if d, ok := ctx.Deadline(); ok && (deadline.IsZero() || d.Before(deadline)) {
+		deadline = d
+	}
+	return deadline
+}
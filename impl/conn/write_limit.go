@@ -0,0 +1,87 @@
+package conn
+
+import (
+	"context"
+
+	"github.com/jumperzq86/jumper_conn/impl/limit"
+	"github.com/jumperzq86/jumper_conn/interf"
+
+	"github.com/jumperzq86/jumper_conn/def"
+)
+
+//出方向限速，limiter 为连接自身的限速，group 为多个连接共享的限速
+type writeLimit struct {
+	limiter interf.Limiter
+	group   interf.Limiter
+}
+
+func newWriteLimit(cc *def.ConnConfig) *writeLimit {
+	return &writeLimit{
+		limiter: limit.CreatetokenBucket(cc.WriteRate, cc.WriteBurst),
+		group:   cc.WriteLimiter,
+	}
+}
+
+func (this *writeLimit) setRate(rate, burst int64) {
+	this.limiter.SetLimit(rate, burst)
+}
+
+//按 burst 分块调用 write，每块写之前等待令牌
+//第一块之前的等待受 ctx 控制，之后只在连接关闭时中断，避免写出半个包
+func (this *writeLimit) write(ctx context.Context, closeCtx context.Context, data []byte, write func([]byte) error) error {
+	chunk := this.chunkSize()
+	if chunk <= 0 || len(data) == 0 {
+		return write(data)
+	}
+
+	waitCtx := ctx
+	for start := 0; start < len(data); start += chunk {
+		end := start + chunk
+		if end > len(data) {
+			end = len(data)
+		}
+
+		err := this.wait(waitCtx, end-start)
+		if err != nil {
+			if waitCtx == closeCtx {
+				return def.ErrConnClosed
+			}
+			return err
+		}
+		waitCtx = closeCtx
+
+		err = write(data[start:end])
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+////////////////////////////////////////////////////////////// impl
+
+//取限速中的 limiter 中最小的 burst，0 表示不限速
+func (this *writeLimit) chunkSize() int {
+	chunk := int64(0)
+	for _, limiter := range []interf.Limiter{this.limiter, this.group} {
+		if limiter == nil {
+			continue
+		}
+		rate, burst := limiter.Limit()
+		if rate > 0 && (chunk == 0 || burst < chunk) {
+			chunk = burst
+		}
+	}
+	return int(chunk)
+}
+
+func (this *writeLimit) wait(ctx context.Context, n int) error {
+	err := this.limiter.WaitN(ctx, n)
+	if err != nil {
+		return err
+	}
+	if this.group != nil {
+		return this.group.WaitN(ctx, n)
+	}
+	return nil
+}
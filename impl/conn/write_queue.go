@@ -0,0 +1,384 @@
+package conn
+
+import (
+	"context"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/jumperzq86/jumper_conn/def"
+)
+
+type writeItem struct {
+	ctx    context.Context
+	data   []byte
+	future *writeFuture
+}
+
+func (this *writeItem) finish(err error) {
+	if this.future != nil {
+		this.future.resolve(err)
+	}
+}
+
+//同一优先级的队列
+type writeLane struct {
+	items   []*writeItem
+	size    int
+	skipped int64 // 有数据但因高优先级队列而未被发送的次数
+}
+
+//AsyncWrite 使用的发送队列，tcpConn 和 wsConn 共用
+//按优先级分为多个 lane，下标越小优先级越高
+type writeQueue struct {
+	dropped uint64
+
+	guard      sync.Mutex
+	lanes      []*writeLane
+	bytes      int64 // 队列中未发送数据的字节数
+	maxBytes   int64
+	starvation int64
+	policy     int8
+	timeout    time.Duration
+
+	waiting   bool
+	readyChan chan struct{} // 通知发送协程队列中有数据
+	spaceChan chan struct{} // 队列腾出空间时 close，唤醒阻塞中的写入方
+
+	stopErr   error         // 不为 nil 时不再接收新的数据
+	busy      bool          // 发送协程正在写出取到的数据
+	inflight  int64         // 正在写出的数据的字节数，写出后才释放全局内存
+	emptyChan chan struct{} // 队列中数据全部写出时 close
+}
+
+func newWriteQueue(cc *def.ConnConfig) *writeQueue {
+	sizes := cc.LaneSizes
+	if len(sizes) == 0 {
+		sizes = []int64{cc.AsyncWriteSize}
+	}
+
+	lanes := make([]*writeLane, 0, len(sizes))
+	for _, size := range sizes {
+		lanes = append(lanes, &writeLane{
+			items: make([]*writeItem, 0, size),
+			size:  int(size),
+		})
+	}
+
+	return &writeQueue{
+		lanes:      lanes,
+		maxBytes:   cc.AsyncWriteBytes,
+		starvation: cc.StarvationLimit,
+		policy:     cc.OverflowPolicy,
+		timeout:    cc.OverflowTimeout,
+		readyChan:  make(chan struct{}, 1),
+		spaceChan:  make(chan struct{}),
+	}
+}
+
+func (this *writeQueue) getDropped() uint64 {
+	return atomic.LoadUint64(&this.dropped)
+}
+
+//队列满时按照 policy 处理，返回的错误用于区分各个策略的结果
+//超出 lane 数量的优先级放入最低优先级的 lane
+//note: OverflowDropOldest 时本次数据入队后返回 nil，被丢弃的旧数据计入 dropped，其 future 以 ErrWriteDropOldest 结束
+func (this *writeQueue) push(ctx context.Context, priority int8, data []byte, future *writeFuture, closeChan <-chan struct{}) error {
+	item := &writeItem{
+		ctx:    ctx,
+		data:   data,
+		future: future,
+	}
+
+	index := int(priority)
+	if index < 0 {
+		index = 0
+	}
+	if index >= len(this.lanes) {
+		index = len(this.lanes) - 1
+	}
+	lane := this.lanes[index]
+
+	var timeoutChan <-chan time.Time
+	if this.policy == def.OverflowBlockTimeout {
+		timer := time.NewTimer(this.timeout)
+		defer timer.Stop()
+		timeoutChan = timer.C
+	}
+
+	size := int64(len(data))
+	for {
+		this.guard.Lock()
+		if this.stopErr != nil {
+			err := this.stopErr
+			this.guard.Unlock()
+			return err
+		}
+		ok, budgetChan, err := this.reserve(lane, size)
+		if err != nil {
+			this.guard.Unlock()
+			atomic.AddUint64(&this.dropped, 1)
+			return err
+		}
+		if ok {
+			this.append(lane, item)
+			this.guard.Unlock()
+			this.notifyReady()
+			return nil
+		}
+
+		switch this.policy {
+		case def.OverflowDropNewest:
+			this.guard.Unlock()
+			atomic.AddUint64(&this.dropped, 1)
+			return def.ErrWriteDropNewest
+		case def.OverflowDropOldest:
+			//按字节限制时可能需要丢弃多条，同一优先级的数据丢完仍放不下时丢弃本次数据
+			for len(lane.items) > 0 {
+				head := this.removeHead(lane)
+				writeBudget.release(int64(len(head.data)))
+				head.finish(def.ErrWriteDropOldest)
+				atomic.AddUint64(&this.dropped, 1)
+				if ok, _, _ = this.reserve(lane, size); ok {
+					this.append(lane, item)
+					this.guard.Unlock()
+					this.notifyReady()
+					return nil
+				}
+			}
+			this.guard.Unlock()
+			atomic.AddUint64(&this.dropped, 1)
+			return def.ErrWriteDropNewest
+		case def.OverflowError:
+			this.guard.Unlock()
+			atomic.AddUint64(&this.dropped, 1)
+			return def.ErrWriteQueueFull
+		case def.OverflowClose:
+			this.guard.Unlock()
+			atomic.AddUint64(&this.dropped, 1)
+			return def.ErrSlowConnClosed
+		}
+
+		this.waiting = true
+		spaceChan := this.spaceChan
+		this.guard.Unlock()
+
+		select {
+		case <-spaceChan:
+		case <-budgetChan:
+		case <-closeChan:
+			return def.ErrConnClosed
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-timeoutChan:
+			atomic.AddUint64(&this.dropped, 1)
+			return def.ErrWriteQueueTimeout
+		}
+	}
+}
+
+//阻塞直到取到数据，连接关闭时返回 false
+func (this *writeQueue) pop(closeChan <-chan struct{}) (*writeItem, bool) {
+	for {
+		this.guard.Lock()
+		lane := this.selectLane()
+		if lane != nil {
+			item := this.removeHead(lane)
+			this.busy = true
+			this.inflight = int64(len(item.data))
+			this.wakeWaiting()
+			this.guard.Unlock()
+			return item, true
+		}
+		this.guard.Unlock()
+
+		select {
+		case <-this.readyChan:
+		case <-closeChan:
+			return nil, false
+		}
+	}
+}
+
+//发送协程处理完 pop 取到的数据后调用，返回队列是否已经为空
+func (this *writeQueue) done() bool {
+	this.guard.Lock()
+	defer this.guard.Unlock()
+	this.busy = false
+	writeBudget.release(this.inflight)
+	this.inflight = 0
+	empty := this.count() == 0
+	if this.emptyChan != nil && empty {
+		close(this.emptyChan)
+		this.emptyChan = nil
+	}
+	return empty
+}
+
+//停止接收新的数据，阻塞中的写入方返回 ErrConnClosing
+func (this *writeQueue) stop() {
+	this.guard.Lock()
+	if this.stopErr == nil {
+		this.stopErr = def.ErrConnClosing
+	}
+	this.wakeWaiting()
+	this.guard.Unlock()
+}
+
+//等待队列中的数据全部写出，超时或连接关闭时返回 false
+func (this *writeQueue) waitEmpty(timeoutChan <-chan struct{}, closeChan <-chan struct{}) bool {
+	this.guard.Lock()
+	if !this.busy && this.count() == 0 {
+		this.guard.Unlock()
+		return true
+	}
+	if this.emptyChan == nil {
+		this.emptyChan = make(chan struct{})
+	}
+	emptyChan := this.emptyChan
+	this.guard.Unlock()
+
+	select {
+	case <-emptyChan:
+		return true
+	case <-timeoutChan:
+		return false
+	case <-closeChan:
+		return false
+	}
+}
+
+//连接关闭时清空队列，未写出的数据以 err 结束
+func (this *writeQueue) clear(err error) {
+	this.guard.Lock()
+	this.stopErr = def.ErrConnClosed
+	this.wakeWaiting()
+	items := make([]*writeItem, 0, this.count())
+	for _, lane := range this.lanes {
+		items = append(items, lane.items...)
+		lane.items = nil
+	}
+	writeBudget.release(this.bytes)
+	this.bytes = 0
+	this.guard.Unlock()
+
+	for _, item := range items {
+		item.finish(err)
+	}
+}
+
+//队列中尚未写出的数据条数
+func (this *writeQueue) size() int {
+	this.guard.Lock()
+	defer this.guard.Unlock()
+	return this.count()
+}
+
+//队列中尚未写出的数据字节数
+func (this *writeQueue) byteSize() int64 {
+	this.guard.Lock()
+	defer this.guard.Unlock()
+	return this.bytes
+}
+
+//队列占用百分比，按条数和字节数中较高的计算
+func (this *writeQueue) fillPercent() int64 {
+	this.guard.Lock()
+	defer this.guard.Unlock()
+
+	capacity := 0
+	for _, lane := range this.lanes {
+		capacity += lane.size
+	}
+	percent := int64(this.count() * 100 / capacity)
+	if this.maxBytes > 0 {
+		bytesPercent := this.bytes * 100 / this.maxBytes
+		if bytesPercent > percent {
+			percent = bytesPercent
+		}
+	}
+	return percent
+}
+
+////////////////////////////////////////////////////////////// impl
+
+//检查条数和字节数限制，满足时占用全局内存，因全局内存不足失败时返回用于等待的 chan
+//note: 队列为空时总是允许，避免超过 maxBytes 的单条数据永远无法发送
+func (this *writeQueue) reserve(lane *writeLane, size int64) (bool, <-chan struct{}, error) {
+	if len(lane.items) >= lane.size {
+		return false, nil, nil
+	}
+	if this.maxBytes > 0 && this.bytes > 0 && this.bytes+size > this.maxBytes {
+		return false, nil, nil
+	}
+	budgetChan, err := writeBudget.acquire(size)
+	return budgetChan == nil && err == nil, budgetChan, err
+}
+
+func (this *writeQueue) append(lane *writeLane, item *writeItem) {
+	lane.items = append(lane.items, item)
+	this.bytes += int64(len(item.data))
+}
+
+func (this *writeQueue) removeHead(lane *writeLane) *writeItem {
+	item := lane.items[0]
+	lane.items[0] = nil
+	lane.items = lane.items[1:]
+	this.bytes -= int64(len(item.data))
+	return item
+}
+
+func (this *writeQueue) count() int {
+	count := 0
+	for _, lane := range this.lanes {
+		count += len(lane.items)
+	}
+	return count
+}
+
+func (this *writeQueue) wakeWaiting() {
+	if this.waiting {
+		this.waiting = false
+		close(this.spaceChan)
+		this.spaceChan = make(chan struct{})
+	}
+}
+
+func (this *writeQueue) notifyReady() {
+	select {
+	case this.readyChan <- struct{}{}:
+	default:
+	}
+}
+
+//优先选择高优先级的 lane，低优先级 lane 连续被跳过 starvation 次后优先发送一次
+func (this *writeQueue) selectLane() *writeLane {
+	index := -1
+	for i, lane := range this.lanes {
+		if len(lane.items) == 0 {
+			continue
+		}
+		if index < 0 {
+			index = i
+			continue
+		}
+		lane.skipped++
+	}
+	if index < 0 {
+		return nil
+	}
+
+	if this.starvation > 0 {
+		for i := index + 1; i < len(this.lanes); i++ {
+			lane := this.lanes[i]
+			if len(lane.items) > 0 && lane.skipped >= this.starvation {
+				index = i
+				break
+			}
+		}
+	}
+
+	lane := this.lanes[index]
+	lane.skipped = 0
+	return lane
+}
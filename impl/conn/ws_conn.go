@@ -1,45 +1,102 @@
 package conn
 
 import (
+	"context"
+	"encoding/binary"
+	"errors"
 	"fmt"
+	"io"
 	"net"
 	"sync"
 	"sync/atomic"
 	"time"
+	"unicode/utf8"
 
 	"github.com/jumperzq86/jumper_conn/interf"
+	"github.com/jumperzq86/jumper_conn/util"
 
 	"github.com/gorilla/websocket"
 	"github.com/jumperzq86/jumper_conn/def"
 )
 
+const (
+	maxCloseTextSize = 123
+	defaultCloseText = "byebye."
+)
+
 type wsConn struct {
-	closed      int32
-	writeBuffer chan []byte
-	closeChan   chan struct{}
-	ctx         map[string]interface{}
+	closed     int32
+	closing    int32
+	closeSent  int32
+	pongSince  int64 // 开始等待 pong 的时间，启动和恢复读取时重置，与最近一次收到 pong 的时间中较晚的一个用于判断心跳超时
+	writeQueue *writeQueue
+	closeChan  chan struct{}
+	closeCtx   context.Context // 连接关闭时取消
+	cancel     context.CancelFunc
+	ctx        map[string]interface{}
 
 	conn    *websocket.Conn
-	co      *def.ConnOptions
+	cc      *def.ConnConfig
+	live    *liveConfig // 运行时可以调整的配置
 	handler interf.Handler
+
+	dataGuard  writeGuard // gorilla/websocket 不支持并发写
+	writeLimit *writeLimit
+	readLimit  *readLimit
+	slow       *slowMonitor
+	dispatcher *dispatcher // 使用协程池时不为 nil
+	events     *handlerEvents
+	middleware *middlewareChain
+	pause      *readPause
+	reason     closeReason
+	idle       *idleTimeout
+	rtt        rttStat
 }
 
+//兼容旧的配置方式，时间字段为秒数
 func CreatewsConn(conn *websocket.Conn, co *def.ConnOptions, handler interf.Handler) (interf.Conn, error) {
-
 	err := co.CheckValid()
 	if err != nil {
 		return nil, err
 	}
+	return CreatewsConnWithConfig(conn, co.Config(), handler)
+}
+
+func CreatewsConnWithConfig(conn *websocket.Conn, cc *def.ConnConfig, handler interf.Handler) (interf.Conn, error) {
+
+	err := cc.CheckValid()
+	if err != nil {
+		return nil, err
+	}
+	err = util.ApplySocketOptions(conn.UnderlyingConn(), cc)
+	if err != nil {
+		return nil, err
+	}
 
 	rc := &wsConn{
-		conn:        conn,
-		closed:      0,
-		writeBuffer: make(chan []byte, co.AsyncWriteSize),
-		closeChan:   make(chan struct{}),
-		co:          co,
-		ctx:         make(map[string]interface{}),
-		handler:     handler,
+		conn:       conn,
+		closed:     0,
+		writeQueue: newWriteQueue(cc),
+		closeChan:  make(chan struct{}),
+		cc:         cc,
+		live:       newLiveConfig(cc),
+		ctx:        make(map[string]interface{}),
+		handler:    handler,
+		dataGuard:  newWriteGuard(),
+		writeLimit: newWriteLimit(cc),
+		readLimit:  newReadLimit(cc),
 	}
+	rc.slow = newSlowMonitor(cc, rc.writeQueue)
+	rc.events = newHandlerEvents(rc, cc, handler)
+	rc.middleware = newMiddlewareChain(cc, rc.events.onMessage)
+	rc.pause = newReadPause(func() {
+		rc.setReadDeadline(rc.live.get().ReadTimeout)
+	}, func() {
+		rc.conn.SetReadDeadline(time.Time{})
+	})
+	rc.idle = newIdleTimeout(cc, rc.pause.isPaused, rc.onIdleTimeout)
+	rc.dispatcher = newDispatcher(cc, rc.closeChan, rc.handleMessage, rc.close)
+	rc.closeCtx, rc.cancel = context.WithCancel(context.Background())
 
 	return rc, nil
 }
@@ -64,31 +121,149 @@ func (this *wsConn) Close() {
 
 	this.close(nil)
 }
+
+//停止接收新的写入，等待队列中的数据写完后发送 close 消息，等待对端回复 close 或超时后关闭连接
+//超时时返回未能发送的消息数
+func (this *wsConn) CloseGracefully(timeout time.Duration) (int, error) {
+	return this.closeGracefully(timeout, nil)
+}
+
+//reason 不为 nil 时作为 OnClose 的错误，ws 在 close 消息中携带其错误信息
+func (this *wsConn) closeGracefully(timeout time.Duration, reason error) (int, error) {
+	if this.IsClosed() {
+		return 0, def.ErrConnClosed
+	}
+	if !atomic.CompareAndSwapInt32(&this.closing, 0, 1) {
+		return 0, def.ErrConnClosing
+	}
+	this.reason.set(reason)
+	this.writeQueue.stop()
+
+	//等待写锁和对端关闭都使用同一个截止时间
+	ctx, cancel := context.WithTimeout(context.Background(), timeout)
+	defer cancel()
+
+	if !this.writeQueue.waitEmpty(ctx.Done(), this.closeChan) {
+		dropped := this.writeQueue.size()
+		if this.IsClosed() {
+			return dropped, def.ErrConnClosed
+		}
+		this.close(def.ErrCloseTimeout)
+		return dropped, def.ErrCloseTimeout
+	}
+
+	//note: 对端回复 close 后 read 协程随之结束
+	this.sendCloseMessage(reason, getWriteDeadline(ctx, this.live.get().WriteTimeout))
+	select {
+	case <-this.closeChan:
+	case <-ctx.Done():
+	}
+
+	this.close(reason)
+	return 0, nil
+}
+
 func (this *wsConn) IsClosed() bool {
 	return atomic.LoadInt32(&this.closed) == 1
 }
 
 func (this *wsConn) Write(data []byte) error {
+	return this.WriteContext(context.Background(), data)
+}
+
+func (this *wsConn) WriteContext(ctx context.Context, data []byte) error {
 	closed := this.IsClosed()
 	if closed {
 		return def.ErrConnClosed
 	}
+	if atomic.LoadInt32(&this.closing) == 1 {
+		return def.ErrConnClosing
+	}
 
-	this.setWriteDeadline(this.co.WriteTimeout)
-	defer this.setWriteDeadline(0)
-
-	err := this.conn.WriteMessage(websocket.TextMessage, data)
+	_, err := this.middleware.write(ctx, this, data, this.writeData)
+	if err == nil {
+		this.idle.touch()
+	}
 	return err
 }
 
 func (this *wsConn) AsyncWrite(data []byte) (err error) {
-	closed := this.IsClosed()
-	if closed {
-		return def.ErrConnClosed
+	return this.AsyncWriteContext(context.Background(), data)
+}
+
+func (this *wsConn) AsyncWriteContext(ctx context.Context, data []byte) (err error) {
+	return this.AsyncWritePriority(ctx, def.PriorityNormal, data)
+}
+
+func (this *wsConn) AsyncWritePriority(ctx context.Context, priority int8, data []byte) (err error) {
+	_, err = this.middleware.write(ctx, this, data, func(ctx context.Context, data []byte) error {
+		return this.enqueue(ctx, priority, data, nil)
+	})
+	return err
+}
+
+func (this *wsConn) AsyncWriteFuture(ctx context.Context, priority int8, data []byte) interf.WriteFuture {
+	future := newWriteFuture()
+	written, err := this.middleware.write(ctx, this, data, func(ctx context.Context, data []byte) error {
+		return this.enqueue(ctx, priority, data, future)
+	})
+	if err == nil && !written {
+		err = def.ErrWriteDroppedByMiddleware
 	}
+	if err != nil || !written {
+		future.resolve(err)
+	}
+	return future
+}
 
-	this.writeBuffer <- data
-	return nil
+func (this *wsConn) RTT() time.Duration {
+	return this.rtt.getRTT()
+}
+
+func (this *wsConn) LastPong() time.Time {
+	return this.rtt.getLastPong()
+}
+
+func (this *wsConn) PauseRead() {
+	this.pause.pause()
+}
+
+func (this *wsConn) ResumeRead() {
+	//暂停期间没有读取 pong，恢复时重新计算心跳超时
+	atomic.StoreInt64(&this.pongSince, time.Now().UnixNano())
+	this.pause.resume()
+}
+
+func (this *wsConn) IsReadPaused() bool {
+	return this.pause.isPaused()
+}
+
+func (this *wsConn) UseInbound(mws ...interf.InboundMiddleware) {
+	this.middleware.useInbound(mws...)
+}
+
+func (this *wsConn) UseOutbound(mws ...interf.OutboundMiddleware) {
+	this.middleware.useOutbound(mws...)
+}
+
+func (this *wsConn) SetWriteRate(rate, burst int64) {
+	this.writeLimit.setRate(rate, burst)
+}
+
+func (this *wsConn) QueueLen() int {
+	return this.writeQueue.size()
+}
+
+func (this *wsConn) QueueBytes() int64 {
+	return this.writeQueue.byteSize()
+}
+
+func (this *wsConn) WriteLatency() time.Duration {
+	return this.slow.getLatency()
+}
+
+func (this *wsConn) Dropped() uint64 {
+	return this.writeQueue.getDropped()
 }
 func (this *wsConn) Set(key string, value interface{}) {
 	this.ctx[key] = value
@@ -105,75 +280,212 @@ func (this *wsConn) Del(key string) {
 	delete(this.ctx, key)
 }
 
+//实现 interf.TaskRunner，PanicClose 时关闭连接
+func (this *wsConn) Go(task func()) {
+	go func() {
+		err := this.events.run(task)
+		if err != nil {
+			this.close(err)
+		}
+	}()
+}
+
 ////////////////////////////////////////////////////////////// impl
+
+//入方向 middleware 和 OnMessage 中的 panic 按 PanicPolicy 处理
+func (this *wsConn) handleMessage(data []byte) (err error) {
+	defer this.events.catch(&err)
+	err = this.middleware.handleMessage(this, data)
+	return handleError(this, this.cc, err, this.closeGracefully)
+}
+
 //服务端和客户端都需要
+//每次读取之前调用，使运行时调整的 MaxMsgSize 在下一帧生效
 func (this *wsConn) setReadLimit() {
-	this.conn.SetReadLimit(this.co.MaxMsgSize)
+	this.conn.SetReadLimit(this.live.get().MaxMsgSize)
 }
 
 //服务端发送ping, 接收pong
 //客户端接收ping, 发送pong, 默认底层处理已经使用回复了pong
+//客户端设置 ClientPing 时同样发送ping, 接收pong
+//ping 内容为发送时间，对端回复的 pong 原样带回，用于计算往返时间
 func (this *wsConn) sendPing() {
 	//note: 若是无需发送ping/pong 就可以设置为0
-	if this.co.PingPeriod == 0 {
+	if this.cc.PingPeriod == 0 {
 		return
 	}
-	ticker := time.NewTicker(time.Duration(this.co.PingPeriod))
+	period := this.live.get().PingPeriod
+	ticker := time.NewTicker(period)
+	defer ticker.Stop()
 
 	for {
 		select {
 		case <-this.closeChan:
 			return
-		case <-ticker.C:
-			this.conn.WriteControl(websocket.PingMessage, nil,
-				time.Now().Add(time.Duration(this.co.WriteTimeout)*time.Second))
+		case now := <-ticker.C:
+			payload := make([]byte, 8)
+			binary.BigEndian.PutUint64(payload, uint64(now.UnixNano()))
+			this.conn.WriteControl(websocket.PingMessage, payload,
+				now.Add(this.live.get().WriteTimeout))
+
+			//PingPeriod 在运行时调整后从下一次 ping 开始生效
+			if p := this.live.get().PingPeriod; p != period {
+				period = p
+				ticker.Reset(period)
+			}
 		}
 	}
 
 }
 
 func (this *wsConn) handlePong() {
-	if this.co.PingPeriod == 0 {
+	if this.cc.PingPeriod == 0 {
 		return
 	}
 	this.conn.SetPongHandler(func(appData string) error {
-		return this.conn.SetReadDeadline(time.Now().Add(time.Duration(this.co.PongWait) * time.Second))
+		this.rtt.pong()
+		if len(appData) == 8 {
+			this.rtt.record(time.Unix(0, int64(binary.BigEndian.Uint64([]byte(appData)))))
+		}
+		return this.conn.SetReadDeadline(time.Now().Add(this.live.get().PongWait))
 	})
 }
 
-func (this *wsConn) setWriteDeadline(timeout int64) {
-	if this.co.WriteTimeout > 0 {
-		this.conn.SetWriteDeadline(time.Now().Add(time.Duration(timeout) * time.Second))
+func (this *wsConn) reconfigure(cu *def.ConnUpdate) error {
+	return this.live.update(cu, this.readLimit, this.writeLimit)
+}
+
+func (this *wsConn) isPinging() bool {
+	return this.cc.PingPeriod != 0 && (this.cc.Side == def.ServerSide || this.cc.ClientPing)
+}
+
+//开启 ping 时超过 PongWait 未收到 pong 属于心跳超时，否则属于空闲超时
+func (this *wsConn) onReadTimeout() {
+	if this.isPinging() {
+		since := time.Unix(0, atomic.LoadInt64(&this.pongSince))
+		if lastPong := this.rtt.getLastPong(); lastPong.After(since) {
+			since = lastPong
+		}
+		if time.Since(since) >= this.live.get().PongWait {
+			this.events.onHeartbeatTimeout()
+			return
+		}
 	}
+	this.events.onIdle()
+}
+
+func (this *wsConn) setWriteDeadline(ctx context.Context) {
+	this.conn.SetWriteDeadline(getWriteDeadline(ctx, this.live.get().WriteTimeout))
 }
 
-func (this *wsConn) setReadDeadline(timeout int64) {
-	if this.co.ReadTimeout > 0 {
-		this.conn.SetReadDeadline(time.Now().Add(time.Duration(timeout) * time.Second))
+func (this *wsConn) setReadDeadline(timeout time.Duration) {
+	if this.live.get().ReadTimeout > 0 {
+		this.conn.SetReadDeadline(time.Now().Add(timeout))
 	}
 }
 
+//ctx 在开始写之前检查，开始写之后其截止时间和 WriteTimeout 中较早的一个作为写超时
+//note: 创建 writer 之后失败时可能已经写出部分帧，gorilla 的连接也不再可写，此时关闭连接
+func (this *wsConn) writeData(ctx context.Context, data []byte) error {
+	err := this.dataGuard.lock(ctx)
+	if err != nil {
+		return err
+	}
+	defer this.dataGuard.unlock()
+
+	err = ctx.Err()
+	if err != nil {
+		return err
+	}
+
+	defer this.conn.SetWriteDeadline(time.Time{})
+
+	//note: 限速时一条消息分多次写入同一个 writer，第一块数据写入前才创建 writer
+	var w io.WriteCloser
+	err = this.writeLimit.write(ctx, this.closeCtx, data, func(chunk []byte) error {
+		this.setWriteDeadline(ctx)
+		if w == nil {
+			var err error
+			w, err = this.conn.NextWriter(websocket.TextMessage)
+			if err != nil {
+				return err
+			}
+		}
+		this.slow.beginWrite()
+		defer this.slow.endWrite()
+		_, err := w.Write(chunk)
+		return err
+	})
+	if w != nil {
+		this.slow.beginWrite()
+		closeErr := w.Close()
+		this.slow.endWrite()
+		if err == nil {
+			err = closeErr
+		}
+		if err != nil {
+			this.close(err)
+		}
+	}
+	return err
+}
+
 func (this *wsConn) close(err error) {
 	if !atomic.CompareAndSwapInt32(&this.closed, 0, 1) {
 		return
 	}
 
 	close(this.closeChan)
+	this.cancel()
+	this.idle.stop()
+	leaveGroup(this.cc.Group, this)
+	this.writeQueue.clear(def.ErrWriteDroppedOnClose)
 
-	if err == nil || err == def.ErrConnClosed {
-		content := websocket.FormatCloseMessage(websocket.CloseNormalClosure, "byebye.")
-		this.conn.WriteMessage(websocket.CloseMessage, content)
-		time.Sleep(time.Duration(this.co.CloseGracePeriod) * time.Second)
+	if (err == nil || errors.Is(err, def.ErrConnClosed)) && this.sendCloseMessage(nil, getWriteDeadline(context.Background(), this.live.get().WriteTimeout)) {
+		time.Sleep(this.cc.CloseGracePeriod)
 	}
 	this.conn.Close()
 
-	this.handler.OnClose(err)
+	this.events.onClose(err)
 
 	this.ctx = nil
 	this.handler = nil
 
 }
 
+//close 消息只发送一次，WriteControl 可以和其他写操作并发调用
+//reason 不为 nil 时以其错误信息作为关闭原因
+//deadline 为零值时不超时
+func (this *wsConn) sendCloseMessage(reason error, deadline time.Time) bool {
+	if !atomic.CompareAndSwapInt32(&this.closeSent, 0, 1) {
+		return false
+	}
+	text := defaultCloseText
+	if reason != nil {
+		text = reason.Error()
+		//control 消息内容不能超过 125 字节，其中 2 字节为关闭码
+		//按字符边界截断，避免对端因 close 原因不是合法的 utf8 而报错
+		if len(text) > maxCloseTextSize {
+			end := maxCloseTextSize
+			for end > 0 && !utf8.RuneStart(text[end]) {
+				end--
+			}
+			text = text[:end]
+		}
+	}
+	content := websocket.FormatCloseMessage(websocket.CloseNormalClosure, text)
+	this.conn.WriteControl(websocket.CloseMessage, content, deadline)
+	return true
+}
+
+func (this *wsConn) onIdleTimeout() {
+	if this.IsClosed() {
+		return
+	}
+	this.events.onIdle()
+	this.close(def.ErrIdleTimeout)
+}
+
 func (this *wsConn) asyncWrite(wg *sync.WaitGroup) error {
 
 	wg.Done()
@@ -186,15 +498,15 @@ readLoop:
 			err = def.ErrConnClosed
 			break readLoop
 
-		case data, ok := <-this.writeBuffer:
+		default:
+			item, ok := this.writeQueue.pop(this.closeChan)
 			if !ok {
 				err = def.ErrConnClosed
 				break readLoop
 			}
 
-			this.setWriteDeadline(this.co.WriteTimeout)
-
-			err = this.conn.WriteMessage(websocket.TextMessage, data)
+			err = this.writeItem(item)
+			drained := this.writeQueue.done()
 			if err != nil {
 				if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
 					err = def.ErrConnClosed
@@ -203,8 +515,12 @@ readLoop:
 				}
 				break readLoop
 			}
-
-			this.setWriteDeadline(0)
+			if drained {
+				err = this.events.onQueueDrained()
+				if err != nil {
+					break readLoop
+				}
+			}
 		}
 	}
 
@@ -212,6 +528,39 @@ readLoop:
 	return err
 }
 
+func (this *wsConn) enqueue(ctx context.Context, priority int8, data []byte, future *writeFuture) (err error) {
+	closed := this.IsClosed()
+	if closed {
+		return def.ErrConnClosed
+	}
+
+	err = this.writeQueue.push(ctx, priority, data, future, this.closeChan)
+	if err == def.ErrSlowConnClosed {
+		this.close(err)
+	}
+	return err
+}
+
+func (this *wsConn) writeItem(item *writeItem) error {
+	//还未写出就已经被取消的数据直接跳过
+	err := item.ctx.Err()
+	if err == nil {
+		err = this.writeData(item.ctx, item.data)
+	}
+	if err == nil {
+		this.idle.touch()
+	}
+	item.finish(err)
+
+	if err != nil && err == item.ctx.Err() {
+		return nil
+	}
+	if err != nil {
+		this.events.onWriteError(item.data, err)
+	}
+	return err
+}
+
 func (this *wsConn) read(wg *sync.WaitGroup) error {
 
 	wg.Done()
@@ -227,20 +576,41 @@ readLoop:
 			break readLoop
 		default:
 
-			this.setReadDeadline(this.co.ReadTimeout)
+			if !this.pause.wait(this.closeChan) {
+				err = def.ErrConnClosed
+				break readLoop
+			}
+
+			this.setReadLimit()
 
-			_, msg, err := this.conn.ReadMessage()
+			var msg []byte
+			_, msg, err = this.conn.ReadMessage()
 
 			if err != nil {
 				if websocket.IsCloseError(err, websocket.CloseNormalClosure) {
-					err = def.ErrConnClosed
+					err = peerCloseError(err.(*websocket.CloseError))
 				}
 				break readLoop
 			}
 
 			this.setReadDeadline(0)
 
-			err = this.handler.OnMessage(msg)
+			this.idle.touch()
+
+			var ok bool
+			ok, err = this.readLimit.check(this.closeCtx, this.events, len(msg))
+			if err != nil {
+				break readLoop
+			}
+			if !ok {
+				continue
+			}
+
+			if this.dispatcher != nil {
+				err = this.dispatcher.dispatch(msg)
+			} else {
+				err = this.handleMessage(msg)
+			}
 			if err != nil {
 				break readLoop
 			}
@@ -248,6 +618,15 @@ readLoop:
 
 	}
 
+	if isTimeout(err) {
+		this.onReadTimeout()
+	}
+
+	//优雅关闭时对端回复 close 属于正常结束
+	if errors.Is(err, def.ErrConnClosed) && atomic.LoadInt32(&this.closing) == 1 {
+		err = this.reason.get()
+	}
+
 	this.close(err)
 	return err
 }
@@ -257,9 +636,10 @@ func (this *wsConn) run() {
 	if this.IsClosed() {
 		return
 	}
+	joinGroup(this.cc.Group, this)
+	this.idle.start()
 
-	this.setReadLimit()
-	if this.co.Side == def.ServerSide {
+	if this.isPinging() {
 		//note: 如下两行基于客户端接收到ping会回复pong的逻辑
 		//	在使用chrome插件 WebSocket 调试工具 发现客户端发送的消息服务端接收不到。
 		//	后来发现是 go this.sendPing() 语句导致的问题
@@ -267,25 +647,47 @@ func (this *wsConn) run() {
 		//	推断客户端插件没有处理 Ping 系统消息
 		//	这里其实起到的就是心跳的作用，因此实际使用时若是客户端未处理Ping，那么就可以不再发送Ping，而是采用自定义的heartbeat来代替。
 		//  因此两个函数中添加检测 PingPeriod==0 就不开启ping/pong 逻辑
+		atomic.StoreInt64(&this.pongSince, time.Now().UnixNano())
 		go this.sendPing()
 		this.handlePong()
 	}
 
+	go this.slow.run(this.events, this.closeChan, this.close)
+
 	wg := &sync.WaitGroup{}
-	wg.Add(2)
+	wg.Add(1)
 
 	go func() {
-		err := this.read(wg)
+		err := this.asyncWrite(wg)
 		if err != nil {
-			fmt.Printf("stop read , err: %s\n", err)
+			fmt.Printf("stop async write , err: %s\n", err)
 		}
 	}()
+
+	wg.Wait()
+
+	//note: 在开始读取之前调用，保证 OnMessage 不会早于 OnOpen
+	err := this.events.onOpen()
+	if err != nil {
+		this.close(err)
+		return
+	}
+
+	wg.Add(1)
 	go func() {
-		err := this.asyncWrite(wg)
+		err := this.read(wg)
 		if err != nil {
-			fmt.Printf("stop async write , err: %s\n", err)
+			fmt.Printf("stop read , err: %s\n", err)
 		}
 	}()
-
 	wg.Wait()
 }
+
+//对端正常关闭时携带的原因以错误码为 ErrConnClosedCode 的错误返回，errors.Is(err, def.ErrConnClosed) 仍然成立
+//没有携带原因时返回 def.ErrConnClosed
+func peerCloseError(ce *websocket.CloseError) error {
+	if ce.Text == "" || ce.Text == defaultCloseText {
+		return def.ErrConnClosed
+	}
+	return def.New(def.ErrConnClosedCode, ce.Text)
+}
@@ -0,0 +1,55 @@
+package group
+
+import (
+	"sync"
+
+	"github.com/jumperzq86/jumper_conn/interf"
+)
+
+type connGroup struct {
+	guard sync.RWMutex
+	conns map[interf.Conn]struct{}
+}
+
+func CreateconnGroup() interf.ConnGroup {
+	return &connGroup{
+		conns: make(map[interf.Conn]struct{}),
+	}
+}
+
+func (this *connGroup) Add(conn interf.Conn) {
+	this.guard.Lock()
+	this.conns[conn] = struct{}{}
+	this.guard.Unlock()
+}
+
+func (this *connGroup) Remove(conn interf.Conn) {
+	this.guard.Lock()
+	delete(this.conns, conn)
+	this.guard.Unlock()
+}
+
+func (this *connGroup) Len() int {
+	this.guard.RLock()
+	defer this.guard.RUnlock()
+	return len(this.conns)
+}
+
+//遍历开始时的快照，遍历期间加入的连接不会被遍历到，已关闭的连接被跳过
+func (this *connGroup) Range(f func(conn interf.Conn) bool) {
+	this.guard.RLock()
+	conns := make([]interf.Conn, 0, len(this.conns))
+	for conn := range this.conns {
+		conns = append(conns, conn)
+	}
+	this.guard.RUnlock()
+
+	for _, conn := range conns {
+		if conn.IsClosed() {
+			continue
+		}
+		if !f(conn) {
+			return
+		}
+	}
+}
@@ -0,0 +1,116 @@
+package limit
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/jumperzq86/jumper_conn/interf"
+)
+
+//令牌桶，令牌不足时预支，由后续的等待时间偿还
+type tokenBucket struct {
+	guard  sync.Mutex
+	rate   int64
+	burst  int64
+	tokens float64
+	last   time.Time
+}
+
+func CreatetokenBucket(rate, burst int64) interf.Limiter {
+	tb := &tokenBucket{}
+	tb.SetLimit(rate, burst)
+	return tb
+}
+
+func (this *tokenBucket) WaitN(ctx context.Context, n int) error {
+	this.guard.Lock()
+	if this.rate <= 0 {
+		this.guard.Unlock()
+		return nil
+	}
+	now := time.Now()
+	this.refill(now)
+	this.tokens -= float64(n)
+	var wait time.Duration
+	if this.tokens < 0 {
+		wait = time.Duration(-this.tokens / float64(this.rate) * float64(time.Second))
+	}
+	this.guard.Unlock()
+
+	if wait == 0 {
+		return nil
+	}
+
+	timer := time.NewTimer(wait)
+	defer timer.Stop()
+	select {
+	case <-timer.C:
+		return nil
+	case <-ctx.Done():
+		//未使用的令牌归还
+		this.guard.Lock()
+		this.tokens += float64(n)
+		if this.tokens > float64(this.burst) {
+			this.tokens = float64(this.burst)
+		}
+		this.guard.Unlock()
+		return ctx.Err()
+	}
+}
+
+func (this *tokenBucket) AllowN(n int) bool {
+	this.guard.Lock()
+	defer this.guard.Unlock()
+	if this.rate <= 0 {
+		return true
+	}
+	this.refill(time.Now())
+	if this.tokens < float64(n) {
+		return false
+	}
+	this.tokens -= float64(n)
+	return true
+}
+
+func (this *tokenBucket) SetLimit(rate, burst int64) {
+	if burst <= 0 {
+		burst = rate
+	}
+
+	this.guard.Lock()
+	defer this.guard.Unlock()
+
+	now := time.Now()
+	if this.rate > 0 {
+		this.refill(now)
+	} else {
+		this.tokens = float64(burst)
+	}
+	this.rate = rate
+	this.burst = burst
+	this.last = now
+	if this.tokens > float64(burst) {
+		this.tokens = float64(burst)
+	}
+}
+
+func (this *tokenBucket) Limit() (int64, int64) {
+	this.guard.Lock()
+	defer this.guard.Unlock()
+	return this.rate, this.burst
+}
+
+////////////////////////////////////////////////////////////// impl
+
+func (this *tokenBucket) refill(now time.Time) {
+	elapsed := now.Sub(this.last)
+	this.last = now
+	if elapsed <= 0 {
+		return
+	}
+	this.tokens += elapsed.Seconds() * float64(this.rate)
+	if this.tokens > float64(this.burst) {
+		this.tokens = float64(this.burst)
+	}
+}
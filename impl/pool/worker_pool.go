@@ -0,0 +1,68 @@
+package pool
+
+import (
+	"sync"
+
+	"github.com/jumperzq86/jumper_conn/interf"
+
+	"github.com/jumperzq86/jumper_conn/def"
+)
+
+//固定数量协程的协程池，所有连接共享
+type workerPool struct {
+	guard  sync.RWMutex
+	closed bool
+	tasks  chan func()
+	wg     sync.WaitGroup
+}
+
+func CreateworkerPool(size int, queueSize int) (interf.WorkerPool, error) {
+	if size <= 0 || queueSize < 0 {
+		return nil, def.ErrInvalidPoolParam
+	}
+
+	wp := &workerPool{
+		tasks: make(chan func(), queueSize),
+	}
+
+	wp.wg.Add(size)
+	for i := 0; i < size; i++ {
+		go wp.work()
+	}
+
+	return wp, nil
+}
+
+func (this *workerPool) Submit(task func()) error {
+	this.guard.RLock()
+	defer this.guard.RUnlock()
+
+	if this.closed {
+		return def.ErrWorkerPoolClosed
+	}
+	this.tasks <- task
+	return nil
+}
+
+//不再接收新的任务，等待已提交的任务执行完
+func (this *workerPool) Close() {
+	this.guard.Lock()
+	if this.closed {
+		this.guard.Unlock()
+		return
+	}
+	this.closed = true
+	close(this.tasks)
+	this.guard.Unlock()
+
+	this.wg.Wait()
+}
+
+////////////////////////////////////////////////////////////// impl
+
+func (this *workerPool) work() {
+	defer this.wg.Done()
+	for task := range this.tasks {
+		task()
+	}
+}
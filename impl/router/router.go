@@ -0,0 +1,106 @@
+package router
+
+import (
+	"encoding/binary"
+
+	"github.com/jumperzq86/jumper_conn/interf"
+	jtd "github.com/jumperzq86/jumper_transform/def"
+	jti "github.com/jumperzq86/jumper_transform/interf"
+
+	"github.com/jumperzq86/jumper_conn/def"
+)
+
+type router struct {
+	conn      interf.Conn
+	ts        jti.Transform
+	ro        *def.RouterOptions
+	handlers  map[uint16]interf.MessageFunc
+	unknown   interf.MessageFunc
+	closeFunc func(conn interf.Conn, err error)
+}
+
+func Createrouter(ro *def.RouterOptions) interf.Router {
+	return &router{
+		ts:       ro.Transform,
+		ro:       ro,
+		handlers: make(map[uint16]interf.MessageFunc),
+	}
+}
+
+func (this *router) Init(conn interf.Conn, ts jti.Transform) {
+	this.conn = conn
+	if this.ts == nil {
+		this.ts = ts
+	}
+}
+
+func (this *router) OnMessage(data []byte) error {
+	if this.ts == nil {
+		return def.ErrRouterNoTransform
+	}
+
+	var msg jti.Message
+	err := this.ts.Execute(jtd.Backward, data, &msg)
+	if err != nil {
+		return err
+	}
+
+	f, ok := this.handlers[msg.Type]
+	if !ok {
+		f = this.unknown
+	}
+	if f == nil {
+		return def.ErrUnknownMsgType
+	}
+	return f(this.conn, &msg)
+}
+
+func (this *router) OnClose(err error) {
+	if this.closeFunc != nil {
+		this.closeFunc(this.conn, err)
+	}
+}
+
+func (this *router) Handle(msgType uint16, f interf.MessageFunc) {
+	this.handlers[msgType] = f
+}
+
+func (this *router) HandleUnknown(f interf.MessageFunc) {
+	this.unknown = f
+}
+
+func (this *router) HandleClose(f func(conn interf.Conn, err error)) {
+	this.closeFunc = f
+}
+
+func (this *router) Send(msgType uint16, content []byte) error {
+	if this.ts == nil {
+		return def.ErrRouterNoTransform
+	}
+
+	msg := &jti.Message{
+		Type:    msgType,
+		Content: content,
+	}
+	var output []byte
+	err := this.ts.Execute(jtd.Forward, msg, &output)
+	if err != nil {
+		return err
+	}
+
+	if this.ro.TcpHead {
+		data := make([]byte, def.TcpHeadSize+len(output))
+		binary.BigEndian.PutUint32(data, uint32(len(output)))
+		copy(data[def.TcpHeadSize:], output)
+		output = data
+	}
+
+	if this.ro.AsyncSend {
+		return this.conn.AsyncWrite(output)
+	}
+	return this.conn.Write(output)
+}
+
+func (this *router) GetConn() interf.Conn {
+	return this.conn
+}
@@ -0,0 +1,272 @@
+package rpc
+
+import (
+	"context"
+	"encoding/binary"
+	"fmt"
+	"math"
+	"runtime/debug"
+	"sync"
+	"time"
+
+	"github.com/jumperzq86/jumper_conn/interf"
+	jti "github.com/jumperzq86/jumper_transform/interf"
+
+	"github.com/jumperzq86/jumper_conn/def"
+)
+
+//帧格式：类型(1) + 请求id(8) + 内容
+//请求内容：方法名长度(2) + 方法名 + 参数
+//错误响应内容：错误码(4) + 错误信息
+const (
+	frameRequest  byte = 1
+	frameResponse byte = 2
+	frameError    byte = 3
+
+	frameHeadSize = 9
+)
+
+type rpcResult struct {
+	payload []byte
+	err     error
+}
+
+type rpc struct {
+	guard   sync.Mutex
+	closed  bool
+	nextId  uint64
+	pending map[uint64]chan *rpcResult
+
+	conn      interf.Conn
+	runner    interf.TaskRunner // 连接实现时处理函数中的 panic 按连接的 PanicPolicy 处理
+	ro        *def.RpcOptions
+	sem       chan struct{} // 限制同时处理的请求数
+	methods   map[string]interf.RpcFunc
+	closeFunc func(conn interf.Conn, err error)
+}
+
+func Createrpc(ro *def.RpcOptions) (interf.Rpc, error) {
+	err := ro.CheckValid()
+	if err != nil {
+		return nil, err
+	}
+
+	maxConcurrent := ro.MaxConcurrent
+	if maxConcurrent == 0 {
+		maxConcurrent = def.RpcMaxConcurrent
+	}
+
+	return &rpc{
+		pending: make(map[uint64]chan *rpcResult),
+		ro:      ro,
+		sem:     make(chan struct{}, maxConcurrent),
+		methods: make(map[string]interf.RpcFunc),
+	}, nil
+}
+
+func (this *rpc) Init(conn interf.Conn, ts jti.Transform) {
+	this.conn = conn
+	this.runner, _ = conn.(interf.TaskRunner)
+}
+
+func (this *rpc) OnMessage(data []byte) error {
+	if len(data) < frameHeadSize {
+		return def.ErrInvalidRpcFrame
+	}
+	id := binary.BigEndian.Uint64(data[1:frameHeadSize])
+	body := data[frameHeadSize:]
+
+	switch data[0] {
+	case frameRequest:
+		if len(body) < 2 || len(body) < 2+int(binary.BigEndian.Uint16(body)) {
+			return def.ErrInvalidRpcFrame
+		}
+		end := 2 + int(binary.BigEndian.Uint16(body))
+		method, payload := string(body[2:end]), body[end:]
+
+		//note: 不在 read 协程中等待，处理函数中的 Call 需要 read 协程读取响应
+		select {
+		case this.sem <- struct{}{}:
+		default:
+			this.conn.AsyncWrite(this.withHead(this.newReply(id, nil, def.ErrRpcBusy)))
+			return nil
+		}
+		this.goServe(func() {
+			defer func() { <-this.sem }()
+			this.serve(id, method, payload)
+		})
+	case frameResponse:
+		this.finish(id, &rpcResult{payload: body})
+	case frameError:
+		if len(body) < 4 {
+			return def.ErrInvalidRpcFrame
+		}
+		code := int32(binary.BigEndian.Uint32(body))
+		this.finish(id, &rpcResult{err: def.New(code, string(body[4:]))})
+	default:
+		return def.ErrInvalidRpcFrame
+	}
+	return nil
+}
+
+//未完成的调用以 def.ErrConnClosed 结束
+func (this *rpc) OnClose(err error) {
+	this.guard.Lock()
+	this.closed = true
+	pending := this.pending
+	this.pending = make(map[uint64]chan *rpcResult)
+	this.guard.Unlock()
+
+	for _, ch := range pending {
+		ch <- &rpcResult{err: def.ErrConnClosed}
+	}
+
+	if this.closeFunc != nil {
+		this.closeFunc(this.conn, err)
+	}
+}
+
+func (this *rpc) Register(method string, f interf.RpcFunc) {
+	this.methods[method] = f
+}
+
+func (this *rpc) HandleClose(f func(conn interf.Conn, err error)) {
+	this.closeFunc = f
+}
+
+//方法名长度超过 65535 时返回 def.ErrInvalidRpcParam
+func (this *rpc) Call(ctx context.Context, method string, payload []byte) ([]byte, error) {
+	if len(method) > math.MaxUint16 {
+		return nil, def.ErrInvalidRpcParam
+	}
+	if _, ok := ctx.Deadline(); !ok && this.ro.CallTimeout > 0 {
+		var cancel context.CancelFunc
+		ctx, cancel = context.WithTimeout(ctx, time.Duration(this.ro.CallTimeout)*time.Second)
+		defer cancel()
+	}
+
+	ch := make(chan *rpcResult, 1)
+	this.guard.Lock()
+	if this.closed {
+		this.guard.Unlock()
+		return nil, def.ErrConnClosed
+	}
+	this.nextId++
+	id := this.nextId
+	this.pending[id] = ch
+	this.guard.Unlock()
+
+	defer func() {
+		this.guard.Lock()
+		delete(this.pending, id)
+		this.guard.Unlock()
+	}()
+
+	data := this.newFrame(frameRequest, id, 2+len(method)+len(payload))
+	data = append(data, 0, 0)
+	binary.BigEndian.PutUint16(data[len(data)-2:], uint16(len(method)))
+	data = append(data, method...)
+	data = append(data, payload...)
+	err := this.conn.WriteContext(ctx, this.withHead(data))
+	if err != nil {
+		return nil, err
+	}
+
+	select {
+	case result := <-ch:
+		return result.payload, result.err
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+func (this *rpc) GetConn() interf.Conn {
+	return this.conn
+}
+
+////////////////////////////////////////////////////////////// impl
+
+func (this *rpc) goServe(task func()) {
+	if this.runner != nil {
+		this.runner.Go(task)
+		return
+	}
+
+	go func() {
+		defer func() {
+			if v := recover(); v != nil {
+				fmt.Printf("rpc handler panic: %v\n%s", v, debug.Stack())
+			}
+		}()
+		task()
+	}()
+}
+
+func (this *rpc) serve(id uint64, method string, payload []byte) {
+	f, ok := this.methods[method]
+	if !ok {
+		this.conn.Write(this.withHead(this.newReply(id, nil, def.ErrRpcMethodNotFound)))
+		return
+	}
+
+	//处理函数 panic 时仍然响应，避免调用方等到超时，panic 继续交给上层处理
+	replied := false
+	defer func() {
+		if !replied {
+			this.conn.Write(this.withHead(this.newReply(id, nil, def.New(def.ErrHandlerPanicCode, "rpc handler panic."))))
+		}
+	}()
+
+	result, err := f(this.conn, payload)
+	replied = true
+
+	//note: 对端已经关闭时无法响应，由对端的 OnClose 结束调用
+	this.conn.Write(this.withHead(this.newReply(id, result, err)))
+}
+
+//构造响应帧，包含预留的长度头
+func (this *rpc) newReply(id uint64, result []byte, err error) []byte {
+	var data []byte
+	if err == nil {
+		data = this.newFrame(frameResponse, id, len(result))
+		data = append(data, result...)
+	} else {
+		code := int32(def.ErrRpcRemoteCode)
+		if e, ok := err.(*def.Error); ok {
+			code = e.Code
+		}
+		data = this.newFrame(frameError, id, 4+len(err.Error()))
+		data = append(data, 0, 0, 0, 0)
+		binary.BigEndian.PutUint32(data[len(data)-4:], uint32(code))
+		data = append(data, err.Error()...)
+	}
+	return data
+}
+
+func (this *rpc) finish(id uint64, result *rpcResult) {
+	this.guard.Lock()
+	ch, ok := this.pending[id]
+	delete(this.pending, id)
+	this.guard.Unlock()
+
+	//调用方已经超时返回的响应直接丢弃
+	if ok {
+		ch <- result
+	}
+}
+
+//预留长度头的空间，返回的切片包含帧头，extra 为预估的内容长度
+func (this *rpc) newFrame(kind byte, id uint64, extra int) []byte {
+	data := make([]byte, def.TcpHeadSize+frameHeadSize, def.TcpHeadSize+frameHeadSize+extra)
+	data[def.TcpHeadSize] = kind
+	binary.BigEndian.PutUint64(data[def.TcpHeadSize+1:], id)
+	return data
+}
+
+func (this *rpc) withHead(data []byte) []byte {
+	if !this.ro.TcpHead {
+		return data[def.TcpHeadSize:]
+	}
+	binary.BigEndian.PutUint32(data, uint32(len(data)-def.TcpHeadSize))
+	return data
+}
@@ -1,16 +1,43 @@
 package interf
 
 import (
+	"context"
 	"net"
+	"time"
 )
 
+type WriteFuture interface {
+	Done() <-chan struct{}          //写入结束（成功、失败或被丢弃）时关闭
+	Err() error                     //写入结果，Done 之前返回 nil
+	Wait(ctx context.Context) error //等待写入结束并返回结果
+}
+
 type Conn interface {
 	GetConn() net.Conn
 	Close()
+	CloseGracefully(timeout time.Duration) (int, error) //停止接收新的写入，发送完队列中的数据后关闭，超时返回未发送的消息数
 	IsClosed() bool
 
 	Write(data []byte) error
 	AsyncWrite(data []byte) error
+	WriteContext(ctx context.Context, data []byte) error                          //ctx 在等待写锁时可取消，截止时间同时作为写超时
+	AsyncWriteContext(ctx context.Context, data []byte) error                     //ctx 在等待队列空间时可取消，发送前已取消的数据不再发送
+	AsyncWritePriority(ctx context.Context, priority int8, data []byte) error     //高优先级的数据先发送，AsyncWrite 使用 def.PriorityNormal
+	AsyncWriteFuture(ctx context.Context, priority int8, data []byte) WriteFuture //连接关闭时未发送的数据以 def.ErrWriteDroppedOnClose 结束，被 middleware 丢弃时以 def.ErrWriteDroppedByMiddleware 结束
+	SetWriteRate(rate, burst int64)                                               //运行时调整出方向限速，rate 为 0 表示不限速
+	QueueLen() int                                                                //发送队列中未发送的消息数
+	QueueBytes() int64                                                            //发送队列中未发送的字节数
+	WriteLatency() time.Duration                                                  //写操作耗时的平滑值
+	Dropped() uint64                                                              //因队列满或超过内存上限而被拒绝或丢弃的消息数，各溢出策略统一计数
+	RTT() time.Duration                                                           //ping/pong 往返时间的平滑值，tcp 使用应用层心跳，没有数据时为 0
+	LastPong() time.Time                                                          //最近一次收到 pong 的时间，没有收到过时为零值
+
+	PauseRead()  //暂停读取，对端发送速度由 tcp 流控降低，暂停期间不会因读超时关闭
+	ResumeRead() //恢复读取，读超时重新计时
+	IsReadPaused() bool
+
+	UseInbound(mws ...InboundMiddleware)   //为该连接添加入方向 middleware，需要在 Run 之前调用
+	UseOutbound(mws ...OutboundMiddleware) //为该连接添加出方向 middleware，需要在 Run 之前调用
 
 	LocalAddr() net.Addr
 	RemoteAddr() net.Addr
@@ -21,3 +48,8 @@ type Conn interface {
 
 	Run()
 }
+
+//连接实现的可选接口，Rpc 等 Handler 通过类型断言检测
+type TaskRunner interface {
+	Go(task func()) //在单独的协程中执行 task，panic 与回调中的 panic 一样按 PanicPolicy 处理
+}
@@ -0,0 +1,9 @@
+package interf
+
+//一组连接，如同一个服务的所有连接，可用于批量调整
+type ConnGroup interface {
+	Add(conn Conn)
+	Remove(conn Conn)
+	Len() int
+	Range(f func(conn Conn) bool) //f 返回 false 时停止遍历，f 中可以调用 Add 和 Remove
+}
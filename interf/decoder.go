@@ -0,0 +1,9 @@
+package interf
+
+import (
+	"io"
+)
+
+type Decoder interface {
+	Decode(r io.Reader) ([]byte, error) //从 r 中读取一个完整的帧，返回剥离头部后的内容
+}
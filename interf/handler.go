@@ -6,6 +6,32 @@ import (
 
 type Handler interface {
 	Init(conn Conn, ts tfi.Transform) //初始化连接和转换
-	OnMessage(data []byte) error  //在该函数实现中使用 transform 进行转换，而不是放在通信代码中
+	OnMessage(data []byte) error      //在该函数实现中使用 transform 进行转换，而不是放在通信代码中
 	OnClose(err error)
 }
+
+//以下为可选接口，Handler 按需实现，连接通过类型断言检测
+
+type OpenHandler interface {
+	OnOpen() //Run 启动写协程之后、开始读取之前调用，OnMessage 不会早于 OnOpen
+}
+
+type WriteErrorHandler interface {
+	OnWriteError(data []byte, err error) //异步写失败时调用，之后连接关闭
+}
+
+type IdleHandler interface {
+	OnIdle() //超过 ReadTimeout 未读到数据或超过 IdleTimeout 没有读写时调用，之后连接关闭
+}
+
+type HeartbeatTimeoutHandler interface {
+	OnHeartbeatTimeout() //ws 超过 PongWait 未收到 pong 或 tcp 心跳超时时调用，之后连接关闭
+}
+
+type QueueDrainedHandler interface {
+	OnQueueDrained() //发送队列中的数据全部写出时调用
+}
+
+type PanicHandler interface {
+	OnPanic(v interface{}, stack []byte) //回调中发生 panic 时调用，之后按 ConnOptions.PanicPolicy 处理
+}
@@ -0,0 +1,12 @@
+package interf
+
+import (
+	"context"
+)
+
+type Limiter interface {
+	WaitN(ctx context.Context, n int) error //阻塞直到获得 n 个令牌
+	AllowN(n int) bool                      //令牌足够时取走 n 个令牌并返回 true，不足时不取
+	SetLimit(rate, burst int64)             //运行时调整速率和突发量，rate <= 0 表示不限速
+	Limit() (rate, burst int64)
+}
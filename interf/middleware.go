@@ -0,0 +1,16 @@
+package interf
+
+import (
+	"context"
+)
+
+//入方向处理函数，链的末端为 Handler.OnMessage
+//middleware 可以修改数据后调用 next，不调用 next 则丢弃该消息，返回错误时连接以该错误关闭
+type InboundHandler func(conn Conn, data []byte) error
+type InboundMiddleware func(next InboundHandler) InboundHandler
+
+//出方向处理函数，链的末端为实际的写入，Write 和 AsyncWrite 系列方法都经过该链
+//middleware 可以修改数据后调用 next，不调用 next 则丢弃该消息，返回的错误作为写入的结果
+//next 使用传入的 ctx 写入，middleware 可以传入与收到的 ctx 无关的 ctx
+type OutboundHandler func(ctx context.Context, conn Conn, data []byte) error
+type OutboundMiddleware func(next OutboundHandler) OutboundHandler
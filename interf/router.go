@@ -0,0 +1,19 @@
+package interf
+
+import (
+	tfi "github.com/jumperzq86/jumper_transform/interf"
+)
+
+//返回错误时连接以该错误关闭
+type MessageFunc func(conn Conn, msg *tfi.Message) error
+
+//按 Message.Type 分发消息的 Handler
+//note: 注册处理函数需要在 Run 之前完成
+type Router interface {
+	Handler
+	Handle(msgType uint16, f MessageFunc)      //注册消息类型对应的处理函数
+	HandleUnknown(f MessageFunc)               //未注册类型的处理函数，未设置时连接以 def.ErrUnknownMsgType 关闭
+	HandleClose(f func(conn Conn, err error))  //连接关闭时调用
+	Send(msgType uint16, content []byte) error //使用 transform 打包后写入连接
+	GetConn() Conn
+}
@@ -0,0 +1,18 @@
+package interf
+
+import (
+	"context"
+)
+
+//返回的 *def.Error 会保留错误码传给调用方，其他错误以 def.ErrRpcRemoteCode 传给调用方
+type RpcFunc func(conn Conn, payload []byte) ([]byte, error)
+
+//基于连接的请求/响应，两端都创建时可以互相调用
+//note: 注册方法需要在 Run 之前完成，每个请求在单独的协程中处理，同时处理的请求数受 RpcOptions.MaxConcurrent 限制，处理函数中可以调用 Call
+type Rpc interface {
+	Handler
+	Register(method string, f RpcFunc)
+	Call(ctx context.Context, method string, payload []byte) ([]byte, error) //连接关闭时未完成的调用返回 def.ErrConnClosed
+	HandleClose(f func(conn Conn, err error))                                //连接关闭时调用
+	GetConn() Conn
+}
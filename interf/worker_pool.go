@@ -0,0 +1,6 @@
+package interf
+
+type WorkerPool interface {
+	Submit(task func()) error //任务队列满时阻塞，协程池关闭后返回错误
+	Close()
+}
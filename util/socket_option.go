@@ -0,0 +1,89 @@
+package util
+
+import (
+	"net"
+	"time"
+
+	"github.com/jumperzq86/jumper_conn/def"
+)
+
+//将 cc 中的 socket 选项应用到 c 上，c 为 tls 连接时应用到其底层连接
+//没有设置任何选项时直接返回，c 不是 tcp 连接时返回 def.ErrSocketOption
+func ApplySocketOptions(c net.Conn, cc *def.ConnConfig) error {
+	if cc.NoDelay == def.SocketDefault && cc.KeepAlive == def.SocketDefault && cc.KeepAlivePeriod == 0 &&
+		cc.ReadBuffer == 0 && cc.WriteBuffer == 0 && cc.Linger == 0 && cc.UserTimeout == 0 {
+		return nil
+	}
+
+	tc, ok := tcpConnOf(c)
+	if !ok {
+		return def.ErrSocketOption
+	}
+
+	var err error
+	if cc.NoDelay != def.SocketDefault {
+		err = tc.SetNoDelay(cc.NoDelay == def.SocketOn)
+		if err != nil {
+			return err
+		}
+	}
+	if cc.KeepAlive != def.SocketDefault {
+		err = tc.SetKeepAlive(cc.KeepAlive == def.SocketOn)
+		if err != nil {
+			return err
+		}
+	}
+	if cc.KeepAlivePeriod > 0 {
+		err = tc.SetKeepAlivePeriod(cc.KeepAlivePeriod)
+		if err != nil {
+			return err
+		}
+	}
+	if cc.ReadBuffer > 0 {
+		err = tc.SetReadBuffer(int(cc.ReadBuffer))
+		if err != nil {
+			return err
+		}
+	}
+	if cc.WriteBuffer > 0 {
+		err = tc.SetWriteBuffer(int(cc.WriteBuffer))
+		if err != nil {
+			return err
+		}
+	}
+	if cc.Linger != 0 {
+		linger := int(cc.Linger / time.Second)
+		if linger < 0 {
+			linger = 0
+		}
+		err = tc.SetLinger(linger)
+		if err != nil {
+			return err
+		}
+	}
+	if cc.UserTimeout > 0 {
+		err = setUserTimeout(tc, int64(cc.UserTimeout/time.Millisecond))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+//读取 c 上实际生效的 socket 选项，只支持 linux
+func GetSocketOptions(c net.Conn) (*def.SocketOptions, error) {
+	tc, ok := tcpConnOf(c)
+	if !ok {
+		return nil, def.ErrSocketOption
+	}
+	return getSocketOptions(tc)
+}
+
+func tcpConnOf(c net.Conn) (*net.TCPConn, bool) {
+	//tls.Conn 等包装过的连接
+	if nc, ok := c.(interface{ NetConn() net.Conn }); ok {
+		c = nc.NetConn()
+	}
+	tc, ok := c.(*net.TCPConn)
+	return tc, ok
+}
@@ -0,0 +1,62 @@
+package util
+
+import (
+	"net"
+	"syscall"
+
+	"github.com/jumperzq86/jumper_conn/def"
+)
+
+//syscall 包中没有定义
+const tcpUserTimeout = 0x12
+
+func setUserTimeout(tc *net.TCPConn, timeout int64) error {
+	rc, err := tc.SyscallConn()
+	if err != nil {
+		return err
+	}
+	var opErr error
+	err = rc.Control(func(fd uintptr) {
+		opErr = syscall.SetsockoptInt(int(fd), syscall.IPPROTO_TCP, tcpUserTimeout, int(timeout))
+	})
+	if err != nil {
+		return err
+	}
+	return opErr
+}
+
+func getSocketOptions(tc *net.TCPConn) (*def.SocketOptions, error) {
+	rc, err := tc.SyscallConn()
+	if err != nil {
+		return nil, err
+	}
+
+	so := &def.SocketOptions{}
+	var opErr error
+	err = rc.Control(func(fd uintptr) {
+		s := int(fd)
+		get := func(level, opt int) int64 {
+			v, err := syscall.GetsockoptInt(s, level, opt)
+			if err != nil && opErr == nil {
+				opErr = err
+			}
+			return int64(v)
+		}
+		so.NoDelay = get(syscall.IPPROTO_TCP, syscall.TCP_NODELAY) != 0
+		so.KeepAlive = get(syscall.SOL_SOCKET, syscall.SO_KEEPALIVE) != 0
+		so.KeepAlivePeriod = get(syscall.IPPROTO_TCP, syscall.TCP_KEEPIDLE)
+		so.ReadBuffer = get(syscall.SOL_SOCKET, syscall.SO_RCVBUF)
+		so.WriteBuffer = get(syscall.SOL_SOCKET, syscall.SO_SNDBUF)
+		so.UserTimeout = get(syscall.IPPROTO_TCP, tcpUserTimeout)
+
+		//note: 只取 linger 结构的第一个字段 l_onoff
+		so.Linger = get(syscall.SOL_SOCKET, syscall.SO_LINGER) != 0
+	})
+	if err != nil {
+		return nil, err
+	}
+	if opErr != nil {
+		return nil, opErr
+	}
+	return so, nil
+}
@@ -0,0 +1,18 @@
+//go:build !linux
+// +build !linux
+
+package util
+
+import (
+	"net"
+
+	"github.com/jumperzq86/jumper_conn/def"
+)
+
+func setUserTimeout(tc *net.TCPConn, timeout int64) error {
+	return def.ErrSocketOption
+}
+
+func getSocketOptions(tc *net.TCPConn) (*def.SocketOptions, error) {
+	return nil, def.ErrSocketOption
+}